@@ -2,15 +2,35 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"runtime/debug"
 
 	"github.com/artuross/kubesource/internal/commands"
 )
 
+// version is set at build time with -ldflags "-X main.version=...".
+var version = ""
+
 func main() {
-	rootCmd := commands.NewKubesourceCommand()
+	rootCmd := commands.NewKubesourceCommand(buildVersion())
 
 	if err := rootCmd.Run(context.Background(), os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 		os.Exit(1)
 	}
 }
+
+// buildVersion returns the version set at build time, or the module version
+// when installed with go install.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	return "dev"
+}
@@ -0,0 +1,94 @@
+package commands_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/commands"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func TestCoverage(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+		err      string
+	}{
+		{
+			name: "not checked by default",
+			source: `
+  - sourceDir: ./source`,
+		},
+		{
+			name: "warn",
+			source: `
+  - sourceDir: ./source
+    coverage: warn`,
+			expected: "  Warning: v1 Service web is not written to any target nor dropped\n",
+		},
+		{
+			name: "error",
+			source: `
+  - sourceDir: ./source
+    coverage: error`,
+			err: "v1 Service web is not written to any target nor dropped",
+		},
+		{
+			name: "dropped resources",
+			source: `
+  - sourceDir: ./source
+    coverage: error
+    drop:
+      - kind: Service
+        metadata:
+          name: web`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rendered := renderedDocuments
+			afs, executor := newSource(t, `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+sources:`+tt.source+`
+    targets:
+      - directory: ./out
+        filter:
+          include:
+            - kind: ConfigMap
+`, &rendered)
+
+			cfg, err := config.LoadConfig(afs, "app")
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			_, err = commands.RenderConfig(afs, executor, &out, cfg, "app")
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			var warnings []string
+			for line := range strings.Lines(out.String()) {
+				if strings.Contains(line, "Warning") {
+					warnings = append(warnings, line)
+				}
+			}
+
+			assert.Equal(t, tt.expected, strings.Join(warnings, ""))
+		})
+	}
+}
+
+func TestCoverageMode(t *testing.T) {
+	assert.Equal(t, config.StrictOff, commands.CoverageMode(config.Source{}, config.StrictOff))
+	assert.Equal(t, config.StrictWarn, commands.CoverageMode(config.Source{}, config.StrictWarn))
+	assert.Equal(t, config.StrictError, commands.CoverageMode(config.Source{Coverage: config.StrictError}, config.StrictWarn))
+}
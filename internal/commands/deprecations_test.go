@@ -0,0 +1,69 @@
+package commands_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/commands"
+)
+
+func TestProcessDirectoryChecksDeprecations(t *testing.T) {
+	rendered := `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+`
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: "yaml", expected: "app/out/CronJob--backup.yaml"},
+		{format: "bundle", expected: "app/out/manifests.yaml"},
+		{format: "helm", expected: "app/out/templates/CronJob--backup.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			afs, executor := newSource(t, `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+kubernetesVersion: v1.30.0
+sources:
+  - sourceDir: ./source
+    targets:
+      - directory: ./out
+        format: `+tt.format+`
+`, &rendered)
+
+			err := commands.ProcessDirectory(afs, executor, io.Discard, "app")
+			require.EqualError(t, err, "checking API versions of app/out: "+tt.expected+": batch/v1beta1 CronJob is removed in 1.25, use batch/v1")
+		})
+	}
+}
+
+func TestProcessDirectoryWarnsOfRemovedAPIs(t *testing.T) {
+	rendered := `apiVersion: batch/v1beta1
+kind: CronJob
+metadata:
+  name: backup
+`
+
+	afs, executor := newSource(t, `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+kubernetesVersion: v1.30.0
+deprecations: warn
+sources:
+  - sourceDir: ./source
+    targets:
+      - directory: ./out
+`, &rendered)
+
+	var out bytes.Buffer
+	require.NoError(t, commands.ProcessDirectory(afs, executor, &out, "app"))
+
+	assert.Contains(t, out.String(), "  Warning: app/out/CronJob--backup.yaml: batch/v1beta1 CronJob is removed in 1.25, use batch/v1\n")
+}
@@ -0,0 +1,147 @@
+package commands_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/commands"
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func TestGetTargetDocuments(t *testing.T) {
+	named := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  namespace: web
+`
+
+	nameless := `apiVersion: v1
+kind: ConfigMap
+data:
+  mode: fast
+`
+
+	job := `apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: migrate-
+spec:
+  backoffLimit: 1
+`
+
+	jobs := job + `---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: migrate-
+spec:
+  backoffLimit: 2
+`
+
+	tests := []struct {
+		name     string
+		rendered string
+		policy   config.NamelessPolicy
+		format   config.Format
+		expected []string
+		warning  string
+		err      string
+	}{
+		{
+			name:     "named",
+			rendered: named,
+			expected: []string{"ConfigMap--web--settings.yaml"},
+		},
+		{
+			name:     "named JSON",
+			rendered: named,
+			format:   config.FormatJSON,
+			expected: []string{"ConfigMap--web--settings.json"},
+		},
+		{
+			name:     "nameless fails by default",
+			rendered: named + "---\n" + nameless,
+			err:      `v1 ConfigMap <unnamed> has neither metadata.name nor metadata.generateName, set nameless to "skip" or "suffix"`,
+		},
+		{
+			name:     "nameless error",
+			rendered: nameless,
+			policy:   config.NamelessPolicyError,
+			err:      "has neither metadata.name nor metadata.generateName",
+		},
+		{
+			name:     "nameless skip",
+			rendered: named + "---\n" + nameless,
+			policy:   config.NamelessPolicySkip,
+			expected: []string{"ConfigMap--web--settings.yaml"},
+			warning:  "  Warning: skipping v1 ConfigMap <unnamed> without metadata.name nor metadata.generateName\n",
+		},
+		{
+			name:     "nameless suffix",
+			rendered: nameless,
+			policy:   config.NamelessPolicySuffix,
+			expected: []string{"ConfigMap--9cdd5056.yaml"},
+		},
+		{
+			name:     "generateName",
+			rendered: job,
+			expected: []string{"Job--migrate-.yaml"},
+		},
+		{
+			name:     "shared generateName",
+			rendered: jobs,
+			expected: []string{"Job--migrate-9e31070a.yaml", "Job--migrate-94fabedc.yaml"},
+		},
+		{
+			name:     "rendered more than once",
+			rendered: named + "---\n" + named,
+			err:      "v1 ConfigMap web/settings is rendered more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			documents, err := manifest.ParseDocuments([]byte(tt.rendered))
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			files, err := commands.GetTargetDocuments(&out, documents, tt.policy, tt.format)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, commands.FileNames(files))
+			assert.Equal(t, tt.warning, out.String())
+		})
+	}
+
+	t.Run("suffix is stable", func(t *testing.T) {
+		documents, err := manifest.ParseDocuments([]byte(nameless + "---\n" + jobs))
+		require.NoError(t, err)
+
+		first, err := commands.GetTargetDocuments(&bytes.Buffer{}, documents, config.NamelessPolicySuffix, "")
+		require.NoError(t, err)
+
+		second, err := commands.GetTargetDocuments(&bytes.Buffer{}, documents, config.NamelessPolicySuffix, "")
+		require.NoError(t, err)
+
+		assert.Equal(t, commands.FileNames(first), commands.FileNames(second))
+	})
+
+	t.Run("shared generateName does not depend on order", func(t *testing.T) {
+		documents, err := manifest.ParseDocuments([]byte(jobs))
+		require.NoError(t, err)
+
+		files, err := commands.GetTargetDocuments(&bytes.Buffer{}, []manifest.ParsedDocument{documents[1], documents[0]}, "", "")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"Job--migrate-94fabedc.yaml", "Job--migrate-9e31070a.yaml"}, commands.FileNames(files))
+	})
+}
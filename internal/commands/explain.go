@@ -0,0 +1,89 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/commandexec"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func newExplainCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "explain",
+		Usage:     "show why each rendered resource was included in or excluded from each target",
+		ArgsUsage: "[directory]",
+		Action:    runExplainCommand,
+	}
+}
+
+func runExplainCommand(ctx context.Context, c *cli.Command) error {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting working directory: %w", err)
+	}
+
+	baseDir := "."
+	if c.Args().Present() {
+		baseDir = path.Clean(c.Args().First())
+	}
+
+	afs := afero.NewBasePathFs(afero.NewOsFs(), workingDir)
+	executor := commandexec.NewExecutor()
+
+	return explainSingleDirectory(afs, executor, baseDir)
+}
+
+func explainSingleDirectory(afs afero.Fs, executor commandexec.CommandExecutor, baseDir string) error {
+	cfg, err := config.LoadConfig(afs, baseDir)
+	if err != nil {
+		return fmt.Errorf("loading config: %w", err)
+	}
+
+	for i, source := range cfg.Sources {
+		sourceDir := path.Join(baseDir, source.SourceDir)
+
+		fmt.Printf("Source directory: %s\n", sourceDir)
+
+		parsedDocuments, err := renderSource(afs, executor, sourceDir)
+		if err != nil {
+			return fmt.Errorf("rendering sources[%d]: %w", i, err)
+		}
+
+		matchers := make([]*manifest.Matcher, 0, len(source.Targets))
+		for _, target := range source.Targets {
+			matchers = append(matchers, manifest.NewMatcher(cfg.TargetFilters(target)))
+		}
+
+		for _, pd := range parsedDocuments {
+			fmt.Printf("  %s\n", pd)
+
+			if index, ok := pd.MatchingSelector(source.Drop); ok {
+				fmt.Printf("    dropped by drop[%d] %s\n", index, source.Drop[index])
+				continue
+			}
+
+			written := false
+			for j, target := range source.Targets {
+				targetPath := filepath.Join(baseDir, target.Directory)
+				decision := matchers[j].Explain(pd)
+				written = written || decision.Included
+
+				fmt.Printf("    %s: %s\n", targetPath, decision)
+			}
+
+			if !written {
+				fmt.Println("    not written to any target")
+			}
+		}
+	}
+
+	return nil
+}
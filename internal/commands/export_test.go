@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"io"
+	"path"
+
+	"github.com/spf13/afero"
+
+	"github.com/artuross/kubesource/pkg/commandexec"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+// ProcessDirectory renders and writes the config in dir with default options.
+func ProcessDirectory(afs afero.Fs, executor commandexec.CommandExecutor, out io.Writer, dir string) error {
+	return processSingleDirectory(afs, executor, options{version: "v1.0.0", out: out}, dir)
+}
+
+// RenderConfig renders the targets of cfg in baseDir with default options,
+// writing warnings to out.
+func RenderConfig(afs afero.Fs, executor commandexec.CommandExecutor, out io.Writer, cfg *config.Config, baseDir string) ([]renderedTarget, error) {
+	return renderConfig(afs, executor, options{version: "v1.0.0", out: out}, cfg, baseDir, path.Join(baseDir, "kubesource.yaml"))
+}
+
+// CoverageMode returns the coverage mode of source when --coverage is flag.
+func CoverageMode(source config.Source, flag config.StrictMode) config.StrictMode {
+	return coverageMode(source, options{coverage: flag})
+}
+
+// FileNames returns the names of files.
+func FileNames(files []targetFile) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.name)
+	}
+
+	return names
+}
+
+var (
+	FormatTarget       = formatTarget
+	GeneratedFiles     = generatedFiles
+	GetTargetDocuments = getTargetDocuments
+	LoadRenderConfig   = loadRenderConfig
+	ProvenanceHeader   = provenanceHeader
+	SelectTargets      = selectTargets
+	WriteDocuments     = writeDocuments
+	WriteTar           = writeTar
+)
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+// bundleFile is the file FormatBundle writes all resources to.
+const bundleFile = "manifests.yaml"
+
+// formatTarget lays out the encoded documents in the target's format and
+// returns the files to write, keyed by their path relative to the target
+// directory. YAML files start with header; JSON cannot hold comments.
+func formatTarget(target config.Target, files []targetFile, header []byte) (map[string][]byte, error) {
+	switch target.Format {
+	case config.FormatJSON:
+		formatted := make(map[string][]byte, len(files))
+		for _, file := range files {
+			formatted[file.name] = file.content
+		}
+
+		return formatted, nil
+
+	case config.FormatBundle:
+		documents := make([][]byte, 0, len(files))
+		for _, file := range files {
+			documents = append(documents, file.content)
+		}
+
+		return map[string][]byte{
+			bundleFile: slices.Concat(header, bytes.Join(documents, []byte("---\n"))),
+		}, nil
+
+	case config.FormatHelm:
+		return helmChart(target, files, header)
+	}
+
+	formatted := make(map[string][]byte, len(files))
+	for _, file := range files {
+		formatted[file.name] = slices.Concat(header, file.content)
+	}
+
+	return formatted, nil
+}
+
+// helmChart returns a Helm chart with every document as a template, except
+// CustomResourceDefinitions, which are placed in crds/. Template delimiters in
+// documents are escaped, so Helm renders them verbatim.
+func helmChart(target config.Target, files []targetFile, header []byte) (map[string][]byte, error) {
+	chart := config.Chart{}
+	if target.Chart != nil {
+		chart = *target.Chart
+	}
+
+	if chart.Name == "" {
+		chart.Name = path.Base(target.Directory)
+	}
+
+	if chart.Version == "" {
+		chart.Version = "0.1.0"
+	}
+
+	metadata := yaml.MapSlice{
+		{Key: "apiVersion", Value: "v2"},
+		{Key: "name", Value: chart.Name},
+		{Key: "version", Value: chart.Version},
+	}
+
+	if chart.AppVersion != "" {
+		metadata = append(metadata, yaml.MapItem{Key: "appVersion", Value: chart.AppVersion})
+	}
+
+	if chart.Description != "" {
+		metadata = append(metadata, yaml.MapItem{Key: "description", Value: chart.Description})
+	}
+
+	metadata = append(metadata, yaml.MapItem{Key: "type", Value: "application"})
+
+	content, err := manifest.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling Chart.yaml: %w", err)
+	}
+
+	formatted := map[string][]byte{
+		"Chart.yaml": slices.Concat(header, content),
+	}
+
+	for _, file := range files {
+		content := file.content
+
+		// Helm installs CRDs from crds/ before templates and never templates them
+		if file.kind != "CustomResourceDefinition" {
+			content = []byte(strings.ReplaceAll(string(content), "{{", `{{ "{{" }}`))
+		}
+
+		formatted[formattedPath(config.FormatHelm, file)] = slices.Concat(header, content)
+	}
+
+	return formatted, nil
+}
+
+// formattedPath returns the path file is written to in format,
+// relative to the target directory.
+func formattedPath(format config.Format, file targetFile) string {
+	switch format {
+	case config.FormatBundle:
+		return bundleFile
+
+	case config.FormatHelm:
+		if file.kind == "CustomResourceDefinition" {
+			return path.Join("crds", file.name)
+		}
+
+		return path.Join("templates", file.name)
+	}
+
+	return file.name
+}
@@ -0,0 +1,150 @@
+package commands_test
+
+import (
+	"bytes"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/commands"
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func TestFormatTarget(t *testing.T) {
+	documents, err := manifest.ParseDocuments([]byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: template
+data:
+  greeting: Hello {{ .Name }}
+`))
+	require.NoError(t, err)
+
+	header := []byte("# Code generated by kubesource v1.0.0. DO NOT EDIT.\n")
+
+	format := func(t *testing.T, target config.Target) map[string]string {
+		t.Helper()
+
+		files, err := commands.GetTargetDocuments(&bytes.Buffer{}, documents, "", target.Format)
+		require.NoError(t, err)
+
+		formatted, err := commands.FormatTarget(target, files, header)
+		require.NoError(t, err)
+
+		contents := make(map[string]string, len(formatted))
+		for name, content := range formatted {
+			contents[name] = string(content)
+		}
+
+		return contents
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		files := format(t, config.Target{Directory: "./out"})
+
+		assert.Equal(t, []string{"ConfigMap--template.yaml", "CustomResourceDefinition--widgets.example.com.yaml"}, slices.Sorted(maps.Keys(files)))
+		assert.Equal(t, string(header)+`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: template
+data:
+  greeting: Hello {{ .Name }}
+`, files["ConfigMap--template.yaml"])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		files := format(t, config.Target{Directory: "./out", Format: config.FormatJSON})
+
+		assert.Equal(t, []string{"ConfigMap--template.json", "CustomResourceDefinition--widgets.example.com.json"}, slices.Sorted(maps.Keys(files)))
+		assert.Equal(t, `{
+  "apiVersion": "v1",
+  "kind": "ConfigMap",
+  "metadata": {
+    "name": "template"
+  },
+  "data": {
+    "greeting": "Hello {{ .Name }}"
+  }
+}
+`, files["ConfigMap--template.json"])
+	})
+
+	t.Run("bundle", func(t *testing.T) {
+		files := format(t, config.Target{Directory: "./out", Format: config.FormatBundle})
+
+		assert.Equal(t, map[string]string{
+			"manifests.yaml": string(header) + `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: template
+data:
+  greeting: Hello {{ .Name }}
+`,
+		}, files)
+	})
+
+	t.Run("helm defaults", func(t *testing.T) {
+		files := format(t, config.Target{Directory: "./charts/widgets", Format: config.FormatHelm})
+
+		assert.Equal(t, []string{
+			"Chart.yaml",
+			"crds/CustomResourceDefinition--widgets.example.com.yaml",
+			"templates/ConfigMap--template.yaml",
+		}, slices.Sorted(maps.Keys(files)))
+
+		assert.Equal(t, string(header)+`apiVersion: v2
+name: widgets
+version: 0.1.0
+type: application
+`, files["Chart.yaml"])
+
+		assert.Equal(t, string(header)+`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+`, files["crds/CustomResourceDefinition--widgets.example.com.yaml"])
+
+		assert.Equal(t, string(header)+`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: template
+data:
+  greeting: Hello {{ "{{" }} .Name }}
+`, files["templates/ConfigMap--template.yaml"])
+	})
+
+	t.Run("helm chart metadata", func(t *testing.T) {
+		files := format(t, config.Target{
+			Directory: "./charts/widgets",
+			Format:    config.FormatHelm,
+			Chart: &config.Chart{
+				Name:        "vendored-widgets",
+				Version:     "1.2.0",
+				AppVersion:  "4.10.0",
+				Description: "vendored widgets",
+			},
+		})
+
+		assert.Equal(t, string(header)+`apiVersion: v2
+name: vendored-widgets
+version: 1.2.0
+appVersion: 4.10.0
+description: vendored widgets
+type: application
+`, files["Chart.yaml"])
+	})
+}
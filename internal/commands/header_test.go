@@ -0,0 +1,83 @@
+package commands_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/commands"
+	"github.com/artuross/kubesource/internal/kustomize"
+)
+
+func TestProvenanceHeader(t *testing.T) {
+	header := commands.ProvenanceHeader("apps/ingress/kubesource.yaml", "apps/ingress/source", []kustomize.HelmChart{
+		{Name: "ingress-nginx", Version: "4.10.0"},
+		{Name: "extras"},
+	}, "v1.2.3")
+
+	assert.Equal(t, `# Code generated by kubesource v1.2.3. DO NOT EDIT.
+# Config: apps/ingress/kubesource.yaml
+# Source: apps/ingress/source (charts: ingress-nginx 4.10.0, extras)
+# Changes to this file are overwritten on the next run; change the config or the source instead.
+`, string(header))
+}
+
+func TestProcessDirectoryWritesHeader(t *testing.T) {
+	rendered := renderedDocuments
+	afs, executor := newSource(t, `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+sources:
+  - sourceDir: ./source
+    targets:
+      - directory: ./out
+`, &rendered)
+
+	require.NoError(t, commands.ProcessDirectory(afs, executor, io.Discard, "app"))
+
+	entries, err := afero.ReadDir(afs, "app/out")
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+
+		content := readFile(t, afs, "app/out/"+entry.Name())
+		assert.True(t, strings.HasPrefix(content, "# Code generated by kubesource v1.0.0. DO NOT EDIT.\n# Config: app/kubesource.yaml\n# Source: app/source\n"), entry.Name())
+	}
+
+	assert.Equal(t, []string{".kubesource-generated", "ConfigMap--settings.yaml", "Service--web.yaml", "kustomization.yaml"}, names)
+}
+
+func TestGeneratedFiles(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	header := "# Code generated by kubesource v1.0.0. DO NOT EDIT.\n"
+
+	files := map[string]string{
+		// listed by the previous run
+		"out/.kubesource-generated":       header + "ConfigMap--settings.json\ntemplates/Service--web.yaml\n../outside.yaml\n",
+		"out/ConfigMap--settings.json":    "{}\n",
+		"out/templates/Service--web.yaml": header + "kind: Service\n",
+		// written before generated files were listed
+		"out/Secret--old.yaml": header + "kind: Secret\n",
+		// hand-written
+		"out/ingress.yaml":       "kind: Ingress\n",
+		"out/kustomization.yaml": "resources: []\n",
+	}
+
+	for name, content := range files {
+		require.NoError(t, afero.WriteFile(afs, name, []byte(content), 0o644))
+	}
+
+	generated, err := commands.GeneratedFiles(afs, "out")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ConfigMap--settings.json", "Secret--old.yaml", "templates/Service--web.yaml"}, generated)
+
+	generated, err = commands.GeneratedFiles(afs, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, generated)
+}
@@ -1,30 +1,112 @@
 package commands
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"maps"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
-	yaml "github.com/goccy/go-yaml"
 	"github.com/spf13/afero"
 	cli "github.com/urfave/cli/v3"
 
+	"github.com/artuross/kubesource/internal/deprecation"
+	"github.com/artuross/kubesource/internal/images"
 	"github.com/artuross/kubesource/internal/kubesource"
 	"github.com/artuross/kubesource/internal/kustomize"
 	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/internal/schema"
+	"github.com/artuross/kubesource/internal/transform"
 	"github.com/artuross/kubesource/pkg/commandexec"
 	"github.com/artuross/kubesource/pkg/config"
 )
 
-func NewKubesourceCommand() *cli.Command {
+func NewKubesourceCommand(version string) *cli.Command {
 	return &cli.Command{
-		Name:   "kubesource",
-		Usage:  "vendor Kubernetes manifests from upstream sources",
-		Action: runKubesourceCommand,
+		Name:    "kubesource",
+		Usage:   "vendor Kubernetes manifests from upstream sources",
+		Version: version,
+		Action:  runKubesourceCommand,
+		Commands: []*cli.Command{
+			newExplainCommand(),
+			newRenderCommand(),
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "strict",
+				Usage: "report selectors that match no resources in targets without a strict setting (warn or error)",
+				Validator: func(value string) error {
+					return config.StrictMode(value).Validate()
+				},
+			},
+			&cli.StringFlag{
+				Name:  "coverage",
+				Usage: "report resources written to no target in sources without a coverage setting (warn or error)",
+				Validator: func(value string) error {
+					return config.StrictMode(value).Validate()
+				},
+			},
+			&cli.StringFlag{
+				Name:  "validation",
+				Usage: "report resources with malformed metadata in sources without a validation setting (warn or error, defaults to warn)",
+				Validator: func(value string) error {
+					return config.StrictMode(value).Validate()
+				},
+			},
+			&cli.StringFlag{
+				Name:  "deprecations",
+				Usage: "report resources using removed API versions in configs without a deprecations setting (warn or error, defaults to error)",
+				Validator: func(value string) error {
+					return config.StrictMode(value).Validate()
+				},
+			},
+			&cli.StringFlag{
+				Name:  "kubernetes-version",
+				Usage: "report resources using API versions deprecated or removed in this Kubernetes version, overrides kubernetesVersion of every config",
+				Validator: func(value string) error {
+					_, err := deprecation.ParseVersion(value)
+					return err
+				},
+			},
+		},
+	}
+}
+
+// options holds command line settings shared by all processed directories.
+type options struct {
+	strict       config.StrictMode
+	coverage     config.StrictMode
+	validation   config.StrictMode
+	deprecations config.StrictMode
+	// kubernetesVersion overrides Config.KubernetesVersion if set.
+	kubernetesVersion string
+	version           string
+	// out receives progress messages and warnings.
+	out io.Writer
+}
+
+// newOptions returns options set by the flags of the root command.
+func newOptions(c *cli.Command, out io.Writer) options {
+	return options{
+		strict:            config.StrictMode(c.String("strict")),
+		coverage:          config.StrictMode(c.String("coverage")),
+		validation:        config.StrictMode(c.String("validation")),
+		deprecations:      config.StrictMode(c.String("deprecations")),
+		kubernetesVersion: c.String("kubernetes-version"),
+		version:           c.Root().Version,
+		out:               out,
 	}
 }
 
@@ -34,6 +116,8 @@ func runKubesourceCommand(ctx context.Context, c *cli.Command) error {
 		log.Fatal(err)
 	}
 
+	opts := newOptions(c, os.Stdout)
+
 	afs := afero.NewBasePathFs(afero.NewOsFs(), workingDir)
 	executor := commandexec.NewExecutor()
 
@@ -43,7 +127,7 @@ func runKubesourceCommand(ctx context.Context, c *cli.Command) error {
 	}
 
 	for _, dir := range directories {
-		if err := processSingleDirectory(afs, executor, dir); err != nil {
+		if err := processSingleDirectory(afs, executor, opts, dir); err != nil {
 			return fmt.Errorf("processing directory %s: %w", dir, err)
 		}
 	}
@@ -51,95 +135,785 @@ func runKubesourceCommand(ctx context.Context, c *cli.Command) error {
 	return nil
 }
 
-func processSingleDirectory(afs afero.Fs, executor commandexec.CommandExecutor, baseDir string) error {
-	fmt.Printf("Processing %s\n", baseDir)
+func processSingleDirectory(afs afero.Fs, executor commandexec.CommandExecutor, opts options, baseDir string) error {
+	fmt.Fprintf(opts.out, "Processing %s\n", baseDir)
 
 	cfg, err := config.LoadConfig(afs, baseDir)
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
 
+	targets, err := renderConfig(afs, executor, opts, cfg, baseDir, path.Join(baseDir, "kubesource.yaml"))
+	if err != nil {
+		return err
+	}
+
+	// save to each target directory
+	for _, rendered := range targets {
+		if err := cleanTargetDirectory(afs, rendered.path, rendered.target.Kustomization, rendered.stale); err != nil {
+			return fmt.Errorf("cleaning target directory %s: %w", rendered.path, err)
+		}
+
+		fmt.Fprintf(opts.out, "  Saving to: %s\n", rendered.path)
+
+		if err := saveFiles(afs, rendered.path, rendered.files); err != nil {
+			return fmt.Errorf("saving to %s: %w", rendered.path, err)
+		}
+	}
+
+	fmt.Fprintf(opts.out, "  ✓ Successfully processed %s\n", baseDir)
+
+	return nil
+}
+
+// renderedTarget holds the output generated for a target before it is written.
+type renderedTarget struct {
+	// path is the target directory, relative to the working directory.
+	path   string
+	target config.Target
+	// documents are the encoded resources in rendered order.
+	documents []targetFile
+	// files are the files to write, keyed by their path relative to the target directory.
+	files map[string][]byte
+	// stale are files generated by a previous run found in the target directory.
+	stale []string
+}
+
+// renderConfig renders every target of cfg, whose paths are relative to baseDir,
+// without writing anything. configPath is shown in the provenance header.
+func renderConfig(afs afero.Fs, executor commandexec.CommandExecutor, opts options, cfg *config.Config, baseDir, configPath string) ([]renderedTarget, error) {
+	var targets []renderedTarget
+
+	kubernetesVersion := cfg.KubernetesVersion
+	if opts.kubernetesVersion != "" {
+		kubernetesVersion = opts.kubernetesVersion
+	}
+
+	deprecations, err := newDeprecationChecker(kubernetesVersion, deprecationMode(cfg, opts))
+	if err != nil {
+		return nil, err
+	}
+
+	imageRegistry := images.NewRegistryResolver(&http.Client{Timeout: registryTimeout})
+
 	for _, source := range cfg.Sources {
 		sourceDir := path.Join(baseDir, source.SourceDir)
 
-		fmt.Printf("  Source directory: %s\n", sourceDir)
+		fmt.Fprintf(opts.out, "  Source directory: %s\n", sourceDir)
 
-		if err := kustomize.VerifyHasKustomizationFile(afs, sourceDir); err != nil {
-			return fmt.Errorf("validating source directory: %w", err)
+		parsedDocuments, err := renderSource(afs, executor, sourceDir)
+		if err != nil {
+			return nil, err
 		}
 
-		kustomizeDocument, err := kustomize.Build(executor, sourceDir)
+		charts, err := kustomize.ReadHelmCharts(afs, sourceDir)
 		if err != nil {
-			return fmt.Errorf("building manifests: %w", err)
+			return nil, err
+		}
+
+		header := provenanceHeader(configPath, sourceDir, charts, opts.version)
+
+		if err := checkMetadata(opts.out, parsedDocuments, validationMode(source, opts)); err != nil {
+			return nil, fmt.Errorf("validating metadata of %s: %w", sourceDir, err)
 		}
 
-		parsedDocuments, err := manifest.ParseDocuments(kustomizeDocument)
+		registry, err := newSchemaRegistry(opts.out, afs, baseDir, kubernetesVersion, source.Schemas, parsedDocuments)
 		if err != nil {
-			return fmt.Errorf("parsing YAML documents: %w", err)
+			return nil, fmt.Errorf("loading schemas for %s: %w", sourceDir, err)
 		}
 
-		// save to each target directory with filtering
+		parsedDocuments, _ = manifest.DropDocuments(parsedDocuments, source.Drop)
+
+		covered := make([]bool, len(parsedDocuments))
+
 		for _, target := range source.Targets {
 			targetPath := filepath.Join(baseDir, target.Directory)
+			matcher := manifest.NewMatcher(cfg.TargetFilters(target))
+
+			var targetDocuments []manifest.ParsedDocument
+			for j, pd := range parsedDocuments {
+				if !matcher.Matches(pd) {
+					continue
+				}
 
-			if err := afs.RemoveAll(targetPath); err != nil {
-				return fmt.Errorf("cleaning target directory %s: %w", targetPath, err)
+				covered[j] = true
+				targetDocuments = append(targetDocuments, pd)
 			}
 
-			includedFiles, err := getTargetDocuments(parsedDocuments, target.Filter)
+			if err := checkUnmatchedSelectors(opts.out, matcher, strictMode(target, opts)); err != nil {
+				return nil, fmt.Errorf("checking filters of %s: %w", targetPath, err)
+			}
+
+			target.Images = target.Images.Merge(source.Images)
+
+			digests, err := newDigestResolver(afs, baseDir, target.Images, imageRegistry)
 			if err != nil {
-				return fmt.Errorf("generating target documents: %w", err)
+				return nil, fmt.Errorf("loading image digests for %s: %w", targetPath, err)
 			}
 
-			fmt.Printf("  Saving to: %s\n", targetPath)
+			generated, err := generatedFiles(afs, targetPath)
+			if err != nil {
+				return nil, fmt.Errorf("listing generated files in %s: %w", targetPath, err)
+			}
 
-			if err := saveFiles(afs, targetPath, includedFiles); err != nil {
-				return fmt.Errorf("saving to %s: %w", targetPath, err)
+			var previous []manifest.ParsedDocument
+			if target.Secrets != nil && target.Secrets.Policy == config.SecretPolicyEncrypt {
+				previous = previousDocuments(afs, targetPath, generated)
 			}
+
+			targetDocuments, err = transform.Apply(target, targetDocuments, transform.Options{
+				SourceDocuments:   parsedDocuments,
+				Digests:           digests,
+				Executor:          executor,
+				PreviousDocuments: previous,
+				Out:               opts.out,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("transforming documents for %s: %w", targetPath, err)
+			}
+
+			documentFiles, err := getTargetDocuments(opts.out, targetDocuments, target.Nameless, target.Format)
+			if err != nil {
+				return nil, fmt.Errorf("generating target documents: %w", err)
+			}
+
+			if err := checkSchemas(opts.out, registry, targetPath, target.Format, documentFiles, source.Schemas); err != nil {
+				return nil, fmt.Errorf("validating resources of %s: %w", targetPath, err)
+			}
+
+			if err := deprecations.check(opts.out, targetPath, target.Format, documentFiles); err != nil {
+				return nil, fmt.Errorf("checking API versions of %s: %w", targetPath, err)
+			}
+
+			includedFiles, err := formatTarget(target, documentFiles, header)
+			if err != nil {
+				return nil, fmt.Errorf("formatting %s: %w", targetPath, err)
+			}
+
+			if target.Format != config.FormatHelm {
+				if err := addKustomization(afs, targetPath, target.Kustomization, includedFiles, generated, header); err != nil {
+					return nil, fmt.Errorf("generating kustomization.yaml for %s: %w", targetPath, err)
+				}
+			}
+
+			addGeneratedList(includedFiles, target.Kustomization, header)
+
+			targets = append(targets, renderedTarget{
+				path:      targetPath,
+				target:    target,
+				documents: documentFiles,
+				files:     includedFiles,
+				stale:     generated,
+			})
+		}
+
+		if err := checkCoverage(opts.out, parsedDocuments, covered, coverageMode(source, opts)); err != nil {
+			return nil, fmt.Errorf("checking coverage of %s: %w", sourceDir, err)
 		}
 	}
 
-	fmt.Printf("  ✓ Successfully processed %s\n", baseDir)
+	return targets, nil
+}
 
-	return nil
+// registryTimeout limits every request to a registry, so an unresponsive
+// registry does not stall the run.
+const registryTimeout = 30 * time.Second
+
+// newDigestResolver returns a resolver of image digests configured by cfg, or
+// nil if images are not pinned. Digests are resolved from registries with
+// registry, which is shared by all targets.
+func newDigestResolver(afs afero.Fs, baseDir string, cfg *config.Images, registry *images.RegistryResolver) (images.DigestResolver, error) {
+	if cfg == nil || cfg.Digests == nil {
+		return nil, nil
+	}
+
+	var resolvers images.Resolvers
+
+	if cfg.Digests.File != "" {
+		digests, err := images.LoadDigestMap(afs, path.Join(baseDir, cfg.Digests.File))
+		if err != nil {
+			return nil, err
+		}
+
+		resolvers = append(resolvers, digests)
+	}
+
+	if cfg.Digests.Resolve {
+		resolvers = append(resolvers, registry)
+	}
+
+	return resolvers, nil
 }
 
-func generateFilename(metadata config.Selector) string {
-	kind := metadata.Kind
-	name := metadata.Metadata.Name
-	namespace := metadata.Metadata.Namespace
+// renderSource builds sourceDir with kustomize and parses the rendered documents.
+func renderSource(afs afero.Fs, executor commandexec.CommandExecutor, sourceDir string) ([]manifest.ParsedDocument, error) {
+	if err := kustomize.VerifyHasKustomizationFile(afs, sourceDir); err != nil {
+		return nil, fmt.Errorf("validating source directory: %w", err)
+	}
+
+	kustomizeDocument, err := kustomize.Build(executor, sourceDir)
+	if err != nil {
+		return nil, fmt.Errorf("building manifests: %w", err)
+	}
+
+	parsedDocuments, err := manifest.ParseDocuments(kustomizeDocument)
+	if err != nil {
+		return nil, fmt.Errorf("parsing YAML documents: %w", err)
+	}
 
+	return parsedDocuments, nil
+}
+
+// generateFilename returns the name of the file a document is written to.
+// Resources named by the API server are named after their metadata.generateName.
+func generateFilename(metadata config.Selector, extension string) string {
+	var name, namespace string
+	if metadata.Metadata != nil {
+		name = metadata.Metadata.Name
+		namespace = metadata.Metadata.Namespace
+
+		if name == "" {
+			name = metadata.Metadata.GenerateName
+		}
+	}
+
+	return filename(metadata.Kind, namespace, name, extension)
+}
+
+func filename(kind, namespace, name, extension string) string {
 	if namespace == "" {
-		return fmt.Sprintf("%s--%s.yaml", kind, name)
+		return fmt.Sprintf("%s--%s%s", kind, name, extension)
 	}
 
-	return fmt.Sprintf("%s--%s--%s.yaml", kind, namespace, name)
+	return fmt.Sprintf("%s--%s--%s%s", kind, namespace, name, extension)
+}
+
+// targetFile is a document encoded for writing to a target directory.
+type targetFile struct {
+	name     string
+	kind     string
+	content  []byte
+	document manifest.ParsedDocument
 }
 
-func getTargetDocuments(documents []manifest.ParsedDocument, filters *config.Filter) (map[string][]byte, error) {
-	includedFiles := make(map[string][]byte, 0)
+// getTargetDocuments encodes documents in format into files named by
+// generateFilename, in the order of documents.
+//
+// Documents whose metadata.generateName is shared with other documents, or
+// with the name of another document, are told apart by a hash of their content
+// appended to the generateName, so their names do not depend on the order of
+// documents. Documents with neither metadata.name nor metadata.generateName
+// are handled according to policy.
+func getTargetDocuments(out io.Writer, documents []manifest.ParsedDocument, policy config.NamelessPolicy, format config.Format) ([]targetFile, error) {
+	marshal, extension := manifest.Marshal, ".yaml"
+	if format == config.FormatJSON {
+		marshal, extension = manifest.MarshalJSON, ".json"
+	}
+
+	files := make([]targetFile, 0, len(documents))
+	names := make(map[string]struct{}, len(documents))
+
+	shared := make(map[string]int, len(documents))
+	for _, pd := range documents {
+		shared[generateFilename(pd.Metadata, extension)]++
+	}
+
 	for _, pd := range documents {
-		if !pd.Matches(filters) {
+		documentContent, err := marshal(pd.Document)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling %s: %w", pd, err)
+		}
+
+		var namespace, name, generateName string
+		if pd.Metadata.Metadata != nil {
+			namespace = pd.Metadata.Metadata.Namespace
+			name = pd.Metadata.Metadata.Name
+			generateName = pd.Metadata.Metadata.GenerateName
+		}
+
+		fileName := generateFilename(pd.Metadata, extension)
+
+		if name == "" && generateName == "" {
+			switch policy {
+			case config.NamelessPolicySkip:
+				fmt.Fprintf(out, "  Warning: skipping %s without metadata.name nor metadata.generateName\n", pd)
+				continue
+
+			case config.NamelessPolicySuffix:
+				fileName = filename(pd.Metadata.Kind, namespace, contentHash(documentContent), extension)
+
+			default:
+				return nil, fmt.Errorf("%s has neither metadata.name nor metadata.generateName, set nameless to %q or %q", pd, config.NamelessPolicySkip, config.NamelessPolicySuffix)
+			}
+		}
+
+		if name == "" && generateName != "" && shared[fileName] > 1 {
+			fileName = filename(pd.Metadata.Kind, namespace, generateName+contentHash(documentContent), extension)
+		}
+
+		if _, ok := names[fileName]; ok {
+			return nil, fmt.Errorf("%s is rendered more than once", pd)
+		}
+
+		names[fileName] = struct{}{}
+		files = append(files, targetFile{name: fileName, kind: pd.Metadata.Kind, content: documentContent, document: pd})
+	}
+
+	return files, nil
+}
+
+// addKustomization adds the kustomization.yaml configured by cfg to files.
+//
+// With cfg.Merge, an existing kustomization.yaml not generated by kubesource
+// is owned by the user: it is updated in place, without header, replacing
+// entries of stale with the current files.
+func addKustomization(afs afero.Fs, targetPath string, cfg *config.Kustomization, files map[string][]byte, stale []string, header []byte) error {
+	if cfg != nil && cfg.Disabled {
+		return nil
+	}
+
+	if cfg != nil && cfg.Merge {
+		existing, err := afero.ReadFile(afs, path.Join(targetPath, kustomize.KustomizationFile))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		if existing != nil && !slices.Contains(stale, kustomize.KustomizationFile) {
+			kustomizationPath, kustomizationData, err := kustomize.MergeKustomizationFile(existing, maps.Keys(files), stale, cfg)
+			if err != nil {
+				return err
+			}
+
+			files[kustomizationPath] = kustomizationData
+
+			return nil
+		}
+	}
+
+	kustomizationPath, kustomizationData, err := kustomize.GenerateKustomizationFile(maps.Keys(files), cfg)
+	if err != nil {
+		return fmt.Errorf("generating kustomization.yaml content: %w", err)
+	}
+
+	if cfg != nil && cfg.Merge {
+		// the file is handed over to the user from now on
+		files[kustomizationPath] = kustomizationData
+		return nil
+	}
+
+	files[kustomizationPath] = slices.Concat(header, kustomizationData)
+
+	return nil
+}
+
+// generatedListFile records the files written to a target directory, so the
+// next run can remove them even if they cannot hold the provenance header,
+// such as JSON files.
+const generatedListFile = ".kubesource-generated"
+
+// addGeneratedList adds generatedListFile listing files to files. A
+// kustomization.yaml owned by the user is not listed.
+func addGeneratedList(files map[string][]byte, cfg *config.Kustomization, header []byte) {
+	var list bytes.Buffer
+	list.Write(header)
+
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if name == kustomize.KustomizationFile && cfg != nil && cfg.Merge {
+			continue
+		}
+
+		list.WriteString(name + "\n")
+	}
+
+	files[generatedListFile] = list.Bytes()
+}
+
+// generatedFiles returns the names of files in targetPath written by a
+// previous run: those listed in generatedListFile and top-level files starting
+// with the provenance header, written before the list was introduced.
+func generatedFiles(afs afero.Fs, targetPath string) ([]string, error) {
+	entries, err := afero.ReadDir(afs, targetPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		content, err := afero.ReadFile(afs, path.Join(targetPath, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		if entry.Name() == generatedListFile {
+			for line := range strings.Lines(string(content)) {
+				line = strings.TrimSpace(line)
+				if line != "" && !strings.HasPrefix(line, "#") && filepath.IsLocal(line) {
+					files = append(files, line)
+				}
+			}
+
+			continue
+		}
+
+		if bytes.HasPrefix(content, []byte(generatedMarker)) {
+			files = append(files, entry.Name())
+		}
+	}
+
+	slices.Sort(files)
+
+	return slices.Compact(files), nil
+}
+
+// previousDocuments returns the documents in the generated files of
+// targetPath. Files that cannot be parsed are skipped.
+func previousDocuments(afs afero.Fs, targetPath string, generated []string) []manifest.ParsedDocument {
+	var documents []manifest.ParsedDocument
+	for _, name := range generated {
+		content, err := afero.ReadFile(afs, path.Join(targetPath, name))
+		if err != nil {
 			continue
 		}
 
-		fileName := generateFilename(pd.Metadata)
-		documentContent, err := yaml.Marshal(pd.Document)
+		parsed, err := manifest.ParseDocuments(content)
 		if err != nil {
-			return nil, fmt.Errorf("marshaling document to YAML: %w", err)
+			continue
+		}
+
+		documents = append(documents, parsed...)
+	}
+
+	return documents
+}
+
+// cleanTargetDirectory removes files of a previous run from targetPath. If the
+// directory holds hand-written files, only generated files are removed.
+func cleanTargetDirectory(afs afero.Fs, targetPath string, cfg *config.Kustomization, generated []string) error {
+	if !cfg.KeepsUserFiles() {
+		return afs.RemoveAll(targetPath)
+	}
+
+	for _, name := range generated {
+		if err := afs.Remove(path.Join(targetPath, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generatedMarker starts the header of every file written by kubesource.
+const generatedMarker = "# Code generated by kubesource"
+
+// provenanceHeader returns a YAML comment written at the top of every generated
+// file, telling readers where the file comes from and not to edit it.
+func provenanceHeader(configPath, sourceDir string, charts []kustomize.HelmChart, version string) []byte {
+	source := sourceDir
+	if len(charts) > 0 {
+		names := make([]string, 0, len(charts))
+		for _, chart := range charts {
+			names = append(names, chart.String())
 		}
 
-		includedFiles[fileName] = documentContent
+		source = fmt.Sprintf("%s (charts: %s)", sourceDir, strings.Join(names, ", "))
+	}
+
+	var header strings.Builder
+
+	fmt.Fprintf(&header, "%s %s. DO NOT EDIT.\n", generatedMarker, version)
+	fmt.Fprintf(&header, "# Config: %s\n", configPath)
+	fmt.Fprintf(&header, "# Source: %s\n", source)
+	fmt.Fprintf(&header, "# Changes to this file are overwritten on the next run; change the config or the source instead.\n")
+
+	return []byte(header.String())
+}
+
+// contentHash returns a short hash of content used to name documents deterministically.
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+
+	return hex.EncodeToString(sum[:4])
+}
+
+// checkUnmatchedSelectors reports selectors that matched no documents according to mode.
+func checkUnmatchedSelectors(out io.Writer, matcher *manifest.Matcher, mode config.StrictMode) error {
+	if mode == config.StrictOff {
+		return nil
+	}
+
+	unmatched := matcher.UnmatchedSelectors()
+	if len(unmatched) == 0 {
+		return nil
 	}
 
-	kustomizationPath, kustomizationData, err := kustomize.GenerateKustomizationFile(maps.Keys(includedFiles))
+	messages := make([]string, 0, len(unmatched))
+	for _, selector := range unmatched {
+		messages = append(messages, fmt.Sprintf("%s %s matched no resources", selector.Path, selector.Selector))
+	}
+
+	if mode == config.StrictError {
+		return errors.New(strings.Join(messages, "; "))
+	}
+
+	for _, message := range messages {
+		fmt.Fprintf(out, "  Warning: %s\n", message)
+	}
+
+	return nil
+}
+
+// checkCoverage reports documents that were not written to any target according to mode.
+func checkCoverage(out io.Writer, documents []manifest.ParsedDocument, covered []bool, mode config.StrictMode) error {
+	if mode == config.StrictOff {
+		return nil
+	}
+
+	var messages []string
+	for i, pd := range documents {
+		if covered[i] {
+			continue
+		}
+
+		messages = append(messages, fmt.Sprintf("%s is not written to any target nor dropped", pd))
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	if mode == config.StrictError {
+		return errors.New(strings.Join(messages, "; "))
+	}
+
+	for _, message := range messages {
+		fmt.Fprintf(out, "  Warning: %s\n", message)
+	}
+
+	return nil
+}
+
+// newSchemaRegistry returns a registry of the schemas configured by cfg, which
+// includes those of the CustomResourceDefinitions in documents, or nil if
+// resources are not validated. Missing schemas for kubernetesVersion are
+// reported as a warning.
+func newSchemaRegistry(out io.Writer, afs afero.Fs, baseDir, kubernetesVersion string, cfg *config.Schemas, documents []manifest.ParsedDocument) (*schema.Registry, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	directories := make([]string, 0, len(cfg.Directories))
+	for _, directory := range cfg.Directories {
+		directories = append(directories, path.Join(baseDir, directory))
+	}
+
+	registry, err := schema.NewRegistry(afs, kubernetesVersion, directories)
 	if err != nil {
-		return nil, fmt.Errorf("generating kustomization.yaml content: %w", err)
+		return nil, err
+	}
+
+	if err := registry.CheckVersion(); err != nil {
+		fmt.Fprintf(out, "  Warning: %s, validating against the bundled schemas, which do not depend on the version\n", err)
 	}
 
-	includedFiles[kustomizationPath] = kustomizationData
+	registry.AddCRDs(documents)
+
+	crds := make([]string, 0, len(cfg.CRDs))
+	for _, crd := range cfg.CRDs {
+		crds = append(crds, path.Join(baseDir, crd))
+	}
+
+	if err := registry.LoadCRDs(crds); err != nil {
+		return nil, err
+	}
 
-	return includedFiles, nil
+	return registry, nil
+}
+
+// checkSchemas reports resources that violate their schemas according to
+// cfg.Mode, with the file they are written to in format and the path of each
+// violation. Resources without a schema are reported as a warning.
+func checkSchemas(out io.Writer, registry *schema.Registry, targetPath string, format config.Format, files []targetFile, cfg *config.Schemas) error {
+	if registry == nil {
+		return nil
+	}
+
+	var messages, missing []string
+	for _, file := range files {
+		errs, found, err := registry.Validate(file.document)
+		if err != nil {
+			return fmt.Errorf("validating %s: %w", file.document, err)
+		}
+
+		if !found {
+			missing = append(missing, file.document.Metadata.APIVersion+" "+file.document.Metadata.Kind)
+			continue
+		}
+
+		for _, err := range errs {
+			messages = append(messages, fmt.Sprintf("%s: %s", path.Join(targetPath, formattedPath(format, file)), err))
+		}
+	}
+
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		fmt.Fprintf(out, "  Warning: no schema found for %s\n", strings.Join(slices.Compact(missing), ", "))
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	if cfg.Mode != config.StrictWarn {
+		return errors.New(strings.Join(messages, "; "))
+	}
+
+	for _, message := range messages {
+		fmt.Fprintf(out, "  Warning: %s\n", message)
+	}
+
+	return nil
+}
+
+// deprecationChecker finds resources using API versions that are deprecated or
+// removed in the Kubernetes version resources are deployed to.
+type deprecationChecker struct {
+	table   *deprecation.Table
+	version deprecation.Version
+	mode    config.StrictMode
+}
+
+// newDeprecationChecker returns a checker for kubernetesVersion reporting
+// removed API versions according to mode, or nil if the version is not set.
+func newDeprecationChecker(kubernetesVersion string, mode config.StrictMode) (*deprecationChecker, error) {
+	if kubernetesVersion == "" {
+		return nil, nil
+	}
+
+	version, err := deprecation.ParseVersion(kubernetesVersion)
+	if err != nil {
+		return nil, fmt.Errorf("parsing kubernetesVersion: %w", err)
+	}
+
+	table, err := deprecation.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &deprecationChecker{table: table, version: version, mode: mode}, nil
+}
+
+// check reports resources using deprecated API versions as warnings. Resources
+// using removed API versions cannot be applied, so they fail the check unless
+// the mode is StrictWarn.
+func (c *deprecationChecker) check(out io.Writer, targetPath string, format config.Format, files []targetFile) error {
+	if c == nil {
+		return nil
+	}
+
+	var removed []string
+	for _, file := range files {
+		finding, ok := c.table.Check(file.document.Metadata.APIVersion, file.document.Metadata.Kind, c.version)
+		if !ok {
+			continue
+		}
+
+		message := fmt.Sprintf("%s: %s", path.Join(targetPath, formattedPath(format, file)), finding)
+		if finding.Removed {
+			removed = append(removed, message)
+			continue
+		}
+
+		fmt.Fprintf(out, "  Warning: %s\n", message)
+	}
+
+	if len(removed) == 0 {
+		return nil
+	}
+
+	if c.mode != config.StrictWarn {
+		return errors.New(strings.Join(removed, "; "))
+	}
+
+	for _, message := range removed {
+		fmt.Fprintf(out, "  Warning: %s\n", message)
+	}
+
+	return nil
+}
+
+// checkMetadata reports documents with malformed metadata according to mode.
+func checkMetadata(out io.Writer, documents []manifest.ParsedDocument, mode config.StrictMode) error {
+	issues := manifest.ValidateMetadata(documents)
+	if len(issues) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+
+	if mode == config.StrictError {
+		return errors.New(strings.Join(messages, "; "))
+	}
+
+	for _, message := range messages {
+		fmt.Fprintf(out, "  Warning: %s\n", message)
+	}
+
+	return nil
+}
+
+func coverageMode(source config.Source, opts options) config.StrictMode {
+	if source.Coverage != config.StrictOff {
+		return source.Coverage
+	}
+
+	return opts.coverage
+}
+
+// validationMode returns the metadata validation mode of source. Unlike other
+// checks, malformed metadata is reported as a warning by default.
+func validationMode(source config.Source, opts options) config.StrictMode {
+	if source.Validation != config.StrictOff {
+		return source.Validation
+	}
+
+	if opts.validation != config.StrictOff {
+		return opts.validation
+	}
+
+	return config.StrictWarn
+}
+
+// deprecationMode returns the mode removed API versions are reported with.
+// Unlike deprecated ones, they are reported as an error by default.
+func deprecationMode(cfg *config.Config, opts options) config.StrictMode {
+	if cfg.Deprecations != config.StrictOff {
+		return cfg.Deprecations
+	}
+
+	if opts.deprecations != config.StrictOff {
+		return opts.deprecations
+	}
+
+	return config.StrictError
+}
+
+func strictMode(target config.Target, opts options) config.StrictMode {
+	if target.Strict != config.StrictOff {
+		return target.Strict
+	}
+
+	return opts.strict
 }
 
 func saveFiles(afs afero.Fs, targetDir string, files map[string][]byte) error {
@@ -149,6 +923,12 @@ func saveFiles(afs afero.Fs, targetDir string, files map[string][]byte) error {
 
 	for relPath, content := range files {
 		filePath := path.Join(targetDir, relPath)
+
+		if dir := path.Dir(filePath); dir != targetDir {
+			if err := afs.MkdirAll(dir, 0o755); err != nil {
+				return fmt.Errorf("creating directory %s: %w", dir, err)
+			}
+		}
 		if err := afero.WriteFile(afs, filePath, content, 0o644); err != nil {
 			return fmt.Errorf("writing file %s: %w", filePath, err)
 		}
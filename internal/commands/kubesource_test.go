@@ -0,0 +1,101 @@
+package commands_test
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/commands"
+	"github.com/artuross/kubesource/pkg/commandexec/commandexectest"
+)
+
+// newSource returns a filesystem with config in app/kubesource.yaml and an
+// executor whose kustomize renders the result of rendered.
+func newSource(t *testing.T, config string, rendered *string) (afero.Fs, *commandexectest.Executor) {
+	t.Helper()
+
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(afs, "app/kubesource.yaml", []byte(config), 0o644))
+	require.NoError(t, afero.WriteFile(afs, "app/source/kustomization.yaml", []byte("resources: []\n"), 0o644))
+
+	sourceDir, err := filepath.Abs("app/source")
+	require.NoError(t, err)
+
+	executor := commandexectest.NewExecutor()
+	executor.AddBinary("kustomize", "/usr/bin/kustomize")
+	executor.AddHandler("kustomize build --enable-helm "+sourceDir, func(string, ...string) ([]byte, error) {
+		return []byte(*rendered), nil
+	})
+
+	return afs, executor
+}
+
+func readFile(t *testing.T, afs afero.Fs, name string) string {
+	t.Helper()
+
+	content, err := afero.ReadFile(afs, name)
+	require.NoError(t, err)
+
+	return string(content)
+}
+
+func TestProcessDirectoryRemovesStaleFiles(t *testing.T) {
+	rendered := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: old
+`
+
+	afs, executor := newSource(t, `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+sources:
+  - sourceDir: ./source
+    targets:
+      - directory: ./out
+        format: json
+        kustomization:
+          merge: true
+`, &rendered)
+
+	require.NoError(t, afero.WriteFile(afs, "app/out/kustomization.yaml", []byte(`resources:
+  - ingress.yaml
+`), 0o644))
+	require.NoError(t, afero.WriteFile(afs, "app/out/ingress.yaml", []byte("kind: Ingress\n"), 0o644))
+
+	require.NoError(t, commands.ProcessDirectory(afs, executor, io.Discard, "app"))
+
+	exists, err := afero.Exists(afs, "app/out/ConfigMap--old.json")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	rendered = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new
+`
+
+	require.NoError(t, commands.ProcessDirectory(afs, executor, io.Discard, "app"))
+
+	exists, err = afero.Exists(afs, "app/out/ConfigMap--old.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	assert.Equal(t, `{
+  "apiVersion": "v1",
+  "kind": "ConfigMap",
+  "metadata": {
+    "name": "new"
+  }
+}
+`, readFile(t, afs, "app/out/ConfigMap--new.json"))
+
+	assert.Equal(t, "kind: Ingress\n", readFile(t, afs, "app/out/ingress.yaml"))
+	assert.Equal(t, `resources:
+  - ingress.yaml
+  - ConfigMap--new.json
+`, readFile(t, afs, "app/out/kustomization.yaml"))
+}
@@ -0,0 +1,243 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/afero"
+	cli "github.com/urfave/cli/v3"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/commandexec"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func newRenderCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "render",
+		Usage:     "render a single config and write the output to stdout, a tar archive or another directory",
+		ArgsUsage: "[directory]",
+		Action:    runRenderCommand,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "config",
+				Usage: "path to the config, or - to read it from stdin (default: kubesource.yaml in directory)",
+			},
+			&cli.StringSliceFlag{
+				Name:  "target",
+				Usage: "render only targets with this directory, may be repeated",
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "directory or archive to write to, or - for stdout",
+				Value:   "-",
+			},
+			&cli.BoolFlag{
+				Name:  "tar",
+				Usage: "write a tar archive of the target directories instead of multi-document YAML or a directory",
+			},
+		},
+	}
+}
+
+func runRenderCommand(ctx context.Context, c *cli.Command) error {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("getting working directory: %w", err)
+	}
+
+	baseDir := "."
+	if c.Args().Present() {
+		baseDir = path.Clean(c.Args().First())
+	}
+
+	afs := afero.NewBasePathFs(afero.NewOsFs(), workingDir)
+	executor := commandexec.NewExecutor()
+
+	// keep stdout for the rendered output
+	opts := newOptions(c, os.Stderr)
+
+	cfg, configPath, err := loadRenderConfig(afs, os.Stdin, baseDir, c.String("config"))
+	if err != nil {
+		return err
+	}
+
+	targets, err := renderConfig(afs, executor, opts, cfg, baseDir, configPath)
+	if err != nil {
+		return err
+	}
+
+	targets, err = selectTargets(targets, baseDir, c.StringSlice("target"))
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+
+	switch {
+	case output == "-" && c.Bool("tar"):
+		return writeTar(os.Stdout, targets)
+
+	case output == "-":
+		return writeDocuments(os.Stdout, targets)
+
+	case c.Bool("tar"):
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("creating archive: %w", err)
+		}
+
+		if err := writeTar(file, targets); err != nil {
+			file.Close()
+			return err
+		}
+
+		return file.Close()
+	}
+
+	outputFs := afero.NewOsFs()
+	for _, rendered := range targets {
+		targetPath := filepath.Join(output, rendered.path)
+
+		fmt.Fprintf(opts.out, "  Saving to: %s\n", targetPath)
+
+		if err := saveFiles(outputFs, targetPath, rendered.files); err != nil {
+			return fmt.Errorf("saving to %s: %w", targetPath, err)
+		}
+	}
+
+	return nil
+}
+
+// loadRenderConfig loads the config at configPath, from stdin if configPath is
+// "-", or kubesource.yaml in baseDir if it is empty. It returns the config and
+// the path shown in the provenance header.
+func loadRenderConfig(afs afero.Fs, stdin io.Reader, baseDir, configPath string) (*config.Config, string, error) {
+	if configPath == "" {
+		cfg, err := config.LoadConfig(afs, baseDir)
+		if err != nil {
+			return nil, "", fmt.Errorf("loading config: %w", err)
+		}
+
+		return cfg, path.Join(baseDir, "kubesource.yaml"), nil
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+
+	if configPath == "-" {
+		data, err = io.ReadAll(stdin)
+		configPath = "<stdin>"
+	} else {
+		data, err = os.ReadFile(configPath)
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("reading config: %w", err)
+	}
+
+	cfg, err := config.ParseConfig(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("loading config from %s: %w", configPath, err)
+	}
+
+	return cfg, configPath, nil
+}
+
+// selectTargets returns targets whose directory, as written in the config or
+// relative to the working directory, is one of directories. All targets are
+// returned if directories is empty.
+func selectTargets(targets []renderedTarget, baseDir string, directories []string) ([]renderedTarget, error) {
+	if len(directories) == 0 {
+		return targets, nil
+	}
+
+	var selected []renderedTarget
+	for _, directory := range directories {
+		directory = filepath.Clean(directory)
+
+		index := slices.IndexFunc(targets, func(rendered renderedTarget) bool {
+			return filepath.Clean(rendered.target.Directory) == directory || rendered.path == directory
+		})
+		if index < 0 {
+			available := make([]string, 0, len(targets))
+			for _, rendered := range targets {
+				available = append(available, rendered.target.Directory)
+			}
+
+			return nil, fmt.Errorf("target %s not found in %s, available targets: %s", directory, baseDir, strings.Join(available, ", "))
+		}
+
+		selected = append(selected, targets[index])
+	}
+
+	return selected, nil
+}
+
+// writeDocuments writes the resources of targets as a multi-document YAML
+// stream, without kustomization.yaml or other files specific to the format.
+// Resources are encoded as YAML whatever the format of their target, as JSON
+// documents cannot be separated by "---".
+func writeDocuments(w io.Writer, targets []renderedTarget) error {
+	var documents [][]byte
+	for _, rendered := range targets {
+		for _, file := range rendered.documents {
+			content, err := manifest.Marshal(file.document.Document)
+			if err != nil {
+				return fmt.Errorf("marshaling %s: %w", file.document, err)
+			}
+
+			documents = append(documents, content)
+		}
+	}
+
+	_, err := w.Write(bytes.Join(documents, []byte("---\n")))
+
+	return err
+}
+
+// writeTar writes the files of targets to a tar archive, under the paths of
+// their target directories.
+func writeTar(w io.Writer, targets []renderedTarget) error {
+	archive := tar.NewWriter(w)
+
+	for _, rendered := range targets {
+		for _, name := range slices.Sorted(maps.Keys(rendered.files)) {
+			content := rendered.files[name]
+
+			header := &tar.Header{
+				Name:    filepath.ToSlash(filepath.Join(rendered.path, name)),
+				Mode:    0o644,
+				Size:    int64(len(content)),
+				ModTime: time.Unix(0, 0),
+			}
+
+			if err := archive.WriteHeader(header); err != nil {
+				return fmt.Errorf("writing archive: %w", err)
+			}
+
+			if _, err := archive.Write(content); err != nil {
+				return fmt.Errorf("writing archive: %w", err)
+			}
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+
+	return nil
+}
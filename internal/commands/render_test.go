@@ -0,0 +1,160 @@
+package commands_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/commands"
+)
+
+const renderConfig = `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+sources:
+  - sourceDir: ./source
+    targets:
+      - directory: ./yaml
+      - directory: ./json
+        format: json
+`
+
+const renderedDocuments = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  mode: fast
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+func TestLoadRenderConfig(t *testing.T) {
+	rendered := renderedDocuments
+	afs, _ := newSource(t, renderConfig, &rendered)
+
+	t.Run("base directory", func(t *testing.T) {
+		cfg, configPath, err := commands.LoadRenderConfig(afs, strings.NewReader(""), "app", "")
+		require.NoError(t, err)
+
+		assert.Equal(t, "app/kubesource.yaml", configPath)
+		assert.Len(t, cfg.Sources[0].Targets, 2)
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		cfg, configPath, err := commands.LoadRenderConfig(afs, strings.NewReader(renderConfig), "app", "-")
+		require.NoError(t, err)
+
+		assert.Equal(t, "<stdin>", configPath)
+		assert.Equal(t, "./json", cfg.Sources[0].Targets[1].Directory)
+	})
+
+	t.Run("invalid stdin", func(t *testing.T) {
+		_, _, err := commands.LoadRenderConfig(afs, strings.NewReader("kind: Config\n"), "app", "-")
+		require.ErrorContains(t, err, "loading config from <stdin>")
+	})
+
+	t.Run("file", func(t *testing.T) {
+		configPath := filepath.Join(t.TempDir(), "staging.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte(renderConfig), 0o644))
+
+		cfg, shownPath, err := commands.LoadRenderConfig(afs, strings.NewReader(""), "app", configPath)
+		require.NoError(t, err)
+
+		assert.Equal(t, configPath, shownPath)
+		assert.Len(t, cfg.Sources, 1)
+	})
+}
+
+func TestRenderOutput(t *testing.T) {
+	rendered := renderedDocuments
+	afs, executor := newSource(t, renderConfig, &rendered)
+
+	cfg, _, err := commands.LoadRenderConfig(afs, strings.NewReader(""), "app", "")
+	require.NoError(t, err)
+
+	targets, err := commands.RenderConfig(afs, executor, io.Discard, cfg, "app")
+	require.NoError(t, err)
+
+	t.Run("select targets", func(t *testing.T) {
+		selected, err := commands.SelectTargets(targets, "app", []string{"json", "app/yaml"})
+		require.NoError(t, err)
+		require.Len(t, selected, 2)
+
+		all, err := commands.SelectTargets(targets, "app", nil)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		_, err = commands.SelectTargets(targets, "app", []string{"./missing"})
+		require.EqualError(t, err, "target missing not found in app, available targets: ./yaml, ./json")
+	})
+
+	t.Run("documents of a JSON target", func(t *testing.T) {
+		selected, err := commands.SelectTargets(targets, "app", []string{"./json"})
+		require.NoError(t, err)
+
+		var out bytes.Buffer
+		require.NoError(t, commands.WriteDocuments(&out, selected))
+
+		assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  mode: fast
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`, out.String())
+	})
+
+	t.Run("tar", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, commands.WriteTar(&out, targets))
+
+		contents := map[string]string{}
+		var names []string
+
+		archive := tar.NewReader(&out)
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+
+			assert.Zero(t, header.ModTime.Unix())
+
+			content, err := io.ReadAll(archive)
+			require.NoError(t, err)
+
+			names = append(names, header.Name)
+			contents[header.Name] = string(content)
+		}
+
+		assert.Equal(t, []string{
+			"app/yaml/.kubesource-generated",
+			"app/yaml/ConfigMap--settings.yaml",
+			"app/yaml/Service--web.yaml",
+			"app/yaml/kustomization.yaml",
+			"app/json/.kubesource-generated",
+			"app/json/ConfigMap--settings.json",
+			"app/json/Service--web.json",
+			"app/json/kustomization.yaml",
+		}, names)
+
+		assert.True(t, strings.HasPrefix(contents["app/yaml/Service--web.yaml"], "# Code generated by kubesource v1.0.0. DO NOT EDIT.\n"))
+		assert.True(t, strings.HasPrefix(contents["app/json/Service--web.json"], "{\n"))
+	})
+}
@@ -0,0 +1,129 @@
+package commands_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/commands"
+	"github.com/artuross/kubesource/pkg/commandexec/commandexectest"
+)
+
+func TestProcessDirectoryValidatesSchemas(t *testing.T) {
+	rendered := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  port: 80
+`
+
+	afs, executor := newSource(t, `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+kubernetesVersion: v1.30.0
+sources:
+  - sourceDir: ./source
+    schemas:
+      mode: error
+    targets:
+      - directory: ./out
+`, &rendered)
+
+	var out bytes.Buffer
+	err := commands.ProcessDirectory(afs, executor, &out, "app")
+
+	require.EqualError(t, err, "validating resources of app/out: app/out/Service--web.yaml: spec.port: unknown field")
+	assert.Contains(t, out.String(), "  Warning: no schema directories for Kubernetes 1.30.0, validating against the bundled schemas, which do not depend on the version\n")
+}
+
+func TestProcessDirectoryValidatesEncryptedSecrets(t *testing.T) {
+	rendered := `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: c2VjcmV0
+`
+
+	afs, executor := newSource(t, `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+sources:
+  - sourceDir: ./source
+    schemas:
+      mode: error
+    targets:
+      - directory: ./out
+        secrets:
+          policy: encrypt
+          age:
+            - age1example
+`, &rendered)
+
+	executor.AddBinary("sops", "/usr/bin/sops")
+
+	var out bytes.Buffer
+	require.NoError(t, commands.ProcessDirectory(afs, sopsExecutor{executor}, &out, "app"))
+
+	assert.Contains(t, readFile(t, afs, "app/out/Secret--credentials.yaml"), "sops:\n")
+	assert.NotContains(t, out.String(), "Warning")
+}
+
+// sopsExecutor imitates sops encryption by appending sops metadata to the
+// document, and delegates other commands.
+type sopsExecutor struct {
+	*commandexectest.Executor
+}
+
+func (e sopsExecutor) Exec(name string, args ...string) ([]byte, error) {
+	if name != "sops" {
+		return e.Executor.Exec(name, args...)
+	}
+
+	content, err := os.ReadFile(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	return append(content, "sops:\n  version: 3.9.0\n"...), nil
+}
+
+func TestProcessDirectoryReportsFormattedPaths(t *testing.T) {
+	rendered := `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  port: 80
+`
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{format: "yaml", expected: "app/out/Service--web.yaml"},
+		{format: "bundle", expected: "app/out/manifests.yaml"},
+		{format: "helm", expected: "app/out/templates/Service--web.yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			afs, executor := newSource(t, `apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+sources:
+  - sourceDir: ./source
+    schemas:
+      mode: error
+    targets:
+      - directory: ./out
+        format: `+tt.format+`
+`, &rendered)
+
+			err := commands.ProcessDirectory(afs, executor, io.Discard, "app")
+			require.EqualError(t, err, "validating resources of app/out: "+tt.expected+": spec.port: unknown field")
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Package deprecation finds resources using API versions that are deprecated
+// or removed in a Kubernetes version, according to a table bundled with
+// kubesource.
+package deprecation
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+
+	_ "embed"
+)
+
+//go:embed deprecations.yaml
+var deprecations []byte
+
+// Version is a Kubernetes minor version, such as 1.30.
+type Version struct {
+	Major int
+	Minor int
+}
+
+// ParseVersion parses a Kubernetes version such as "1.30", "1.30.2" or
+// "v1.30.2-eks". Only the major and minor versions are kept.
+func ParseVersion(value string) (Version, error) {
+	parts := strings.SplitN(strings.TrimPrefix(value, "v"), ".", 3)
+	if len(parts) < 2 {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q, expected e.g. 1.30.0", value)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q, expected e.g. 1.30.0", value)
+	}
+
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Version{}, fmt.Errorf("invalid Kubernetes version %q, expected e.g. 1.30.0", value)
+	}
+
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// AtLeast reports whether v is the same as or later than other.
+func (v Version) AtLeast(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major > other.Major
+	}
+
+	return v.Minor >= other.Minor
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Deprecation describes an API version of a kind that is deprecated in
+// DeprecatedIn and removed in RemovedIn. Replacement is the API version to
+// migrate to, empty if the kind has no replacement.
+type Deprecation struct {
+	APIVersion   string
+	Kind         string
+	DeprecatedIn Version
+	RemovedIn    Version
+	Replacement  string
+}
+
+// Finding is a resource using a deprecated API version.
+type Finding struct {
+	Deprecation
+	// Removed is true if the API version is no longer served.
+	Removed bool
+}
+
+// String describes the finding, e.g. "batch/v1beta1 CronJob is removed in 1.25, use batch/v1".
+func (f Finding) String() string {
+	message := fmt.Sprintf("%s %s is deprecated since %s and removed in %s", f.APIVersion, f.Kind, f.DeprecatedIn, f.RemovedIn)
+	if f.Removed {
+		message = fmt.Sprintf("%s %s is removed in %s", f.APIVersion, f.Kind, f.RemovedIn)
+	}
+
+	if f.Replacement == "" {
+		return message + ", without a replacement"
+	}
+
+	return message + ", use " + f.Replacement
+}
+
+// Table holds known deprecations by API version and kind.
+type Table struct {
+	deprecations map[string]Deprecation
+}
+
+type entry struct {
+	APIVersion   string `yaml:"apiVersion"`
+	Kind         string `yaml:"kind"`
+	DeprecatedIn string `yaml:"deprecatedIn"`
+	RemovedIn    string `yaml:"removedIn"`
+	Replacement  string `yaml:"replacement"`
+}
+
+// Load returns the table bundled with kubesource.
+func Load() (*Table, error) {
+	var entries []entry
+	if err := yaml.Unmarshal(deprecations, &entries); err != nil {
+		return nil, fmt.Errorf("parsing bundled deprecations: %w", err)
+	}
+
+	table := &Table{deprecations: make(map[string]Deprecation, len(entries))}
+	for _, entry := range entries {
+		deprecatedIn, err := ParseVersion(entry.DeprecatedIn)
+		if err != nil {
+			return nil, fmt.Errorf("parsing bundled deprecation of %s %s: %w", entry.APIVersion, entry.Kind, err)
+		}
+
+		removedIn, err := ParseVersion(entry.RemovedIn)
+		if err != nil {
+			return nil, fmt.Errorf("parsing bundled deprecation of %s %s: %w", entry.APIVersion, entry.Kind, err)
+		}
+
+		table.deprecations[entry.APIVersion+" "+entry.Kind] = Deprecation{
+			APIVersion:   entry.APIVersion,
+			Kind:         entry.Kind,
+			DeprecatedIn: deprecatedIn,
+			RemovedIn:    removedIn,
+			Replacement:  entry.Replacement,
+		}
+	}
+
+	return table, nil
+}
+
+// Check reports whether kind in apiVersion is deprecated or removed in version.
+func (t *Table) Check(apiVersion, kind string, version Version) (Finding, bool) {
+	deprecation, ok := t.deprecations[apiVersion+" "+kind]
+	if !ok || !version.AtLeast(deprecation.DeprecatedIn) {
+		return Finding{}, false
+	}
+
+	return Finding{
+		Deprecation: deprecation,
+		Removed:     version.AtLeast(deprecation.RemovedIn),
+	}, true
+}
@@ -0,0 +1,107 @@
+package deprecation_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/deprecation"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected deprecation.Version
+		wantErr  bool
+	}{
+		{value: "1.30", expected: deprecation.Version{Major: 1, Minor: 30}},
+		{value: "1.30.2", expected: deprecation.Version{Major: 1, Minor: 30}},
+		{value: "v1.25.0-eks-1", expected: deprecation.Version{Major: 1, Minor: 25}},
+		{value: "1", wantErr: true},
+		{value: "latest", wantErr: true},
+		{value: "1.x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			version, err := deprecation.ParseVersion(tt.value)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, version)
+		})
+	}
+}
+
+func TestTableCheck(t *testing.T) {
+	table, err := deprecation.Load()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		apiVersion string
+		kind       string
+		version    string
+		expected   string
+	}{
+		{
+			name:       "not deprecated yet",
+			apiVersion: "autoscaling/v2beta2",
+			kind:       "HorizontalPodAutoscaler",
+			version:    "1.22",
+		},
+		{
+			name:       "deprecated",
+			apiVersion: "autoscaling/v2beta2",
+			kind:       "HorizontalPodAutoscaler",
+			version:    "1.23.4",
+			expected:   "autoscaling/v2beta2 HorizontalPodAutoscaler is deprecated since 1.23 and removed in 1.26, use autoscaling/v2",
+		},
+		{
+			name:       "removed",
+			apiVersion: "autoscaling/v2beta2",
+			kind:       "HorizontalPodAutoscaler",
+			version:    "1.30",
+			expected:   "autoscaling/v2beta2 HorizontalPodAutoscaler is removed in 1.26, use autoscaling/v2",
+		},
+		{
+			name:       "removed without replacement",
+			apiVersion: "policy/v1beta1",
+			kind:       "PodSecurityPolicy",
+			version:    "1.25",
+			expected:   "policy/v1beta1 PodSecurityPolicy is removed in 1.25, without a replacement",
+		},
+		{
+			name:       "other kind in the same API version",
+			apiVersion: "policy/v1beta1",
+			kind:       "Eviction",
+			version:    "1.30",
+		},
+		{
+			name:       "current API version",
+			apiVersion: "apps/v1",
+			kind:       "Deployment",
+			version:    "1.30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := deprecation.ParseVersion(tt.version)
+			require.NoError(t, err)
+
+			finding, ok := table.Check(tt.apiVersion, tt.kind, version)
+			if tt.expected == "" {
+				assert.False(t, ok)
+				return
+			}
+
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, finding.String())
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package images
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/spf13/afero"
+)
+
+// ErrDigestNotFound is returned when the digest of an image is not known.
+var ErrDigestNotFound = errors.New("digest not found")
+
+// manifestMediaTypes are accepted when resolving digests, so multi-platform
+// images resolve to the digest of their index.
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// DigestResolver returns the digest of an image, e.g. "sha256:...".
+type DigestResolver interface {
+	Digest(ref Reference) (string, error)
+}
+
+// DigestMap maps images in the form name:tag to their digests. Names are
+// normalized, so "nginx:1.25" and "docker.io/library/nginx:1.25" are the same image.
+type DigestMap map[string]string
+
+// LoadDigestMap loads a YAML mapping of images to digests, e.g.
+//
+//	nginx:1.25: sha256:...
+//	quay.io/jetstack/cert-manager-controller:v1.14.0: sha256:...
+func LoadDigestMap(afs afero.Fs, path string) (DigestMap, error) {
+	data, err := afero.ReadFile(afs, path)
+	if err != nil {
+		return nil, fmt.Errorf("reading digest map %s: %w", path, err)
+	}
+
+	var entries map[string]string
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing digest map %s: %w", path, err)
+	}
+
+	digests := make(DigestMap, len(entries))
+	for image, digest := range entries {
+		if !strings.HasPrefix(digest, "sha256:") {
+			return nil, fmt.Errorf("parsing digest map %s: invalid digest %q of %s", path, digest, image)
+		}
+
+		digests[digestMapKey(ParseReference(image))] = digest
+	}
+
+	return digests, nil
+}
+
+// Digest returns the digest of ref from the map.
+func (m DigestMap) Digest(ref Reference) (string, error) {
+	digest, ok := m[digestMapKey(ref)]
+	if !ok {
+		return "", ErrDigestNotFound
+	}
+
+	return digest, nil
+}
+
+func digestMapKey(ref Reference) string {
+	return ref.Name + ":" + ref.TagOrLatest()
+}
+
+// Resolvers tries each resolver in order until one knows the digest.
+type Resolvers []DigestResolver
+
+// Digest returns the digest from the first resolver that knows it.
+func (r Resolvers) Digest(ref Reference) (string, error) {
+	for _, resolver := range r {
+		digest, err := resolver.Digest(ref)
+		if errors.Is(err, ErrDigestNotFound) {
+			continue
+		}
+
+		return digest, err
+	}
+
+	return "", ErrDigestNotFound
+}
+
+// RegistryResolver resolves digests from registries implementing the OCI
+// distribution API. Anonymous bearer token authentication is supported.
+// Registries on loopback addresses, such as a local registry, are accessed over plain HTTP.
+//
+// Results, including errors, are cached, so every image is looked up once.
+type RegistryResolver struct {
+	client *http.Client
+	cache  map[string]cachedDigest
+}
+
+type cachedDigest struct {
+	digest string
+	err    error
+}
+
+func NewRegistryResolver(client *http.Client) *RegistryResolver {
+	return &RegistryResolver{client: client, cache: make(map[string]cachedDigest)}
+}
+
+// Digest returns the digest of the manifest of ref.
+func (r *RegistryResolver) Digest(ref Reference) (string, error) {
+	key := digestMapKey(ref)
+	if cached, ok := r.cache[key]; ok {
+		return cached.digest, cached.err
+	}
+
+	digest, err := r.resolve(ref)
+	r.cache[key] = cachedDigest{digest: digest, err: err}
+
+	return digest, err
+}
+
+func (r *RegistryResolver) resolve(ref Reference) (string, error) {
+	manifestURL := url.URL{
+		Scheme: registryScheme(ref.Registry()),
+		Host:   registryHost(ref.Registry()),
+		Path:   fmt.Sprintf("/v2/%s/manifests/%s", ref.Repository(), ref.TagOrLatest()),
+	}
+
+	var token string
+
+	resp, err := r.requestManifest(http.MethodHead, manifestURL.String(), token)
+	if err != nil {
+		return "", err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err = r.fetchToken(resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return "", fmt.Errorf("authenticating to %s: %w", ref.Registry(), err)
+		}
+
+		resp, err = r.requestManifest(http.MethodHead, manifestURL.String(), token)
+		if err != nil {
+			return "", err
+		}
+
+		resp.Body.Close()
+	}
+
+	if err := checkManifestStatus(ref, resp); err != nil {
+		return "", err
+	}
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// the digest header is optional, compute it from the manifest
+	resp, err = r.requestManifest(http.MethodGet, manifestURL.String(), token)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := checkManifestStatus(ref, resp); err != nil {
+		return "", err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading manifest of %s: %w", ref, err)
+	}
+
+	sum := sha256.Sum256(body)
+
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+func (r *RegistryResolver) requestManifest(method, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, manifestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting %s: %w", manifestURL, err)
+	}
+
+	return resp, nil
+}
+
+func checkManifestStatus(ref Reference, resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrDigestNotFound
+	default:
+		return fmt.Errorf("resolving %s: unexpected status %s", ref, resp.Status)
+	}
+}
+
+// fetchToken requests an anonymous token for a Bearer WWW-Authenticate challenge.
+func (r *RegistryResolver) fetchToken(challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication scheme %q", scheme)
+	}
+
+	values := parseChallengeParams(params)
+
+	tokenURL, err := url.Parse(values["realm"])
+	if err != nil || values["realm"] == "" {
+		return "", fmt.Errorf("invalid realm %q", values["realm"])
+	}
+
+	query := tokenURL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if value := values[key]; value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	tokenURL.RawQuery = query.Encode()
+
+	resp, err := r.client.Get(tokenURL.String())
+	if err != nil {
+		return "", fmt.Errorf("requesting token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting token: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding token: %w", err)
+	}
+
+	if body.Token != "" {
+		return body.Token, nil
+	}
+
+	return body.AccessToken, nil
+}
+
+// parseChallengeParams parses comma separated key="value" pairs.
+func parseChallengeParams(params string) map[string]string {
+	values := make(map[string]string)
+
+	for params != "" {
+		key, rest, found := strings.Cut(params, "=")
+		if !found {
+			break
+		}
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+
+		values[strings.TrimSpace(key)] = value
+		params = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+
+	return values
+}
+
+func registryHost(registry string) string {
+	if registry == DefaultRegistry {
+		return "registry-1.docker.io"
+	}
+
+	return registry
+}
+
+func registryScheme(registry string) string {
+	host, _, err := net.SplitHostPort(registry)
+	if err != nil {
+		host = registry
+	}
+
+	if host == "localhost" {
+		return "http"
+	}
+
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return "http"
+	}
+
+	return "https"
+}
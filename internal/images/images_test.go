@@ -0,0 +1,107 @@
+package images_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/images"
+)
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected images.Reference
+	}{
+		{image: "nginx", expected: images.Reference{Name: "docker.io/library/nginx"}},
+		{image: "nginx:1.25", expected: images.Reference{Name: "docker.io/library/nginx", Tag: "1.25"}},
+		{image: "bitnami/redis:7", expected: images.Reference{Name: "docker.io/bitnami/redis", Tag: "7"}},
+		{image: "quay.io/jetstack/cert-manager-controller:v1.14.0", expected: images.Reference{Name: "quay.io/jetstack/cert-manager-controller", Tag: "v1.14.0"}},
+		{image: "localhost:5000/tool", expected: images.Reference{Name: "localhost:5000/tool"}},
+		{image: "registry.k8s.io/pause:3.9@sha256:abc", expected: images.Reference{Name: "registry.k8s.io/pause", Tag: "3.9", Digest: "sha256:abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			assert.Equal(t, tt.expected, images.ParseReference(tt.image))
+		})
+	}
+}
+
+func TestDigestMap(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(afs, "digests.yaml", []byte(`nginx:1.25: sha256:1111
+quay.io/jetstack/cert-manager-controller:v1.14.0: sha256:2222
+`), 0o644))
+
+	digests, err := images.LoadDigestMap(afs, "digests.yaml")
+	require.NoError(t, err)
+
+	digest, err := digests.Digest(images.ParseReference("docker.io/library/nginx:1.25"))
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:1111", digest)
+
+	_, err = digests.Digest(images.ParseReference("nginx:1.26"))
+	require.ErrorIs(t, err, images.ErrDigestNotFound)
+
+	require.NoError(t, afero.WriteFile(afs, "invalid.yaml", []byte(`nginx:1.25: latest`), 0o644))
+
+	_, err = images.LoadDigestMap(afs, "invalid.yaml")
+	require.ErrorContains(t, err, `invalid digest "latest"`)
+}
+
+func TestRegistryResolver(t *testing.T) {
+	const digest = "sha256:3333"
+
+	requests := 0
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		switch {
+		case r.URL.Path == "/token":
+			assert.Equal(t, "repository:team/app:pull", r.URL.Query().Get("scope"))
+			_, _ = w.Write([]byte(`{"token": "secret"}`))
+
+		case r.Header.Get("Authorization") != "Bearer secret":
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="registry",scope="repository:team/app:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+
+		case r.URL.Path == "/v2/team/app/manifests/1.0":
+			assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json")
+			w.Header().Set("Docker-Content-Digest", digest)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := strings.TrimPrefix(server.URL, "http://")
+	resolver := images.NewRegistryResolver(server.Client())
+
+	resolved, err := resolver.Digest(images.ParseReference(registry + "/team/app:1.0"))
+	require.NoError(t, err)
+	assert.Equal(t, digest, resolved)
+
+	_, err = resolver.Digest(images.ParseReference(registry + "/team/app:2.0"))
+	require.ErrorIs(t, err, images.ErrDigestNotFound)
+
+	// lookups are cached
+	made := requests
+
+	resolved, err = images.Resolvers{images.DigestMap{}, resolver}.Digest(images.ParseReference(registry + "/team/app:1.0"))
+	require.NoError(t, err)
+	assert.Equal(t, digest, resolved)
+
+	_, err = resolver.Digest(images.ParseReference(registry + "/team/app:2.0"))
+	require.ErrorIs(t, err, images.ErrDigestNotFound)
+
+	assert.Equal(t, made, requests)
+}
@@ -0,0 +1,87 @@
+package images
+
+import (
+	"strings"
+)
+
+// DefaultRegistry is the registry of images without an explicit registry.
+const DefaultRegistry = "docker.io"
+
+// Reference is a container image reference split into its parts. Name is
+// fully qualified, e.g. "docker.io/library/nginx".
+type Reference struct {
+	Name   string
+	Tag    string
+	Digest string
+}
+
+// ParseReference parses an image reference, adding the default registry and
+// the "library/" prefix of official Docker Hub images when missing.
+func ParseReference(image string) Reference {
+	var ref Reference
+
+	name, digest, found := strings.Cut(image, "@")
+	if found {
+		ref.Digest = digest
+	}
+
+	// a colon after the last slash separates the tag; colons before it belong to the registry port
+	if index := strings.LastIndex(name, ":"); index > strings.LastIndex(name, "/") {
+		name, ref.Tag = name[:index], name[index+1:]
+	}
+
+	domain, rest, found := strings.Cut(name, "/")
+	if !found || !IsRegistry(domain) {
+		domain, rest = DefaultRegistry, name
+	}
+
+	if domain == DefaultRegistry && !strings.Contains(rest, "/") {
+		rest = "library/" + rest
+	}
+
+	ref.Name = domain + "/" + rest
+
+	return ref
+}
+
+// IsRegistry reports whether the first component of an image name is a
+// registry host rather than a Docker Hub namespace.
+func IsRegistry(component string) bool {
+	return strings.ContainsAny(component, ".:") || component == "localhost"
+}
+
+// Registry returns the registry host of the reference.
+func (r Reference) Registry() string {
+	registry, _, _ := strings.Cut(r.Name, "/")
+	return registry
+}
+
+// Repository returns the repository of the reference without the registry.
+func (r Reference) Repository() string {
+	_, repository, _ := strings.Cut(r.Name, "/")
+	return repository
+}
+
+// TagOrLatest returns the tag of the reference, or "latest" if it has none.
+func (r Reference) TagOrLatest() string {
+	if r.Tag == "" {
+		return "latest"
+	}
+
+	return r.Tag
+}
+
+// String returns the reference in the form name[:tag][@digest].
+func (r Reference) String() string {
+	image := r.Name
+
+	if r.Tag != "" {
+		image += ":" + r.Tag
+	}
+
+	if r.Digest != "" {
+		image += "@" + r.Digest
+	}
+
+	return image
+}
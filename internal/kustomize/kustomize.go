@@ -1,17 +1,24 @@
 package kustomize
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"iter"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"slices"
+	"strings"
 
 	yaml "github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
 	"github.com/spf13/afero"
 
+	"github.com/artuross/kubesource/internal/manifest"
 	"github.com/artuross/kubesource/pkg/commandexec"
+	"github.com/artuross/kubesource/pkg/config"
 )
 
 // Build builds a directory with Kustomize.
@@ -37,22 +44,355 @@ func Build(executor commandexec.CommandExecutor, sourceDir string) ([]byte, erro
 	return output, nil
 }
 
+// KustomizationFile is the name of the kustomization written to target directories.
+const KustomizationFile = "kustomization.yaml"
+
 // GenerateKustomizationFile generates a kustomization.yaml file and returns its relative path and content.
-func GenerateKustomizationFile(manifestFiles iter.Seq[string]) (string, []byte, error) {
-	kustomizationPath := "kustomization.yaml"
+// The kustomization lists manifestFiles and the resources, patches, namespace and labels configured in cfg.
+func GenerateKustomizationFile(manifestFiles iter.Seq[string], cfg *config.Kustomization) (string, []byte, error) {
+	kustomization := yaml.MapSlice{
+		{Key: "apiVersion", Value: "kustomize.config.k8s.io/v1beta1"},
+		{Key: "kind", Value: "Kustomization"},
+	}
+
+	return marshalKustomization(kustomization, manifestFiles, nil, cfg)
+}
+
+// MergeKustomizationFile updates a user-owned kustomization.yaml and returns its relative path and content.
+// Entries of stale, files generated by a previous run, are removed from its resources before
+// manifestFiles and the settings of cfg are added. The file is edited in place, so comments,
+// formatting and other fields are kept as they are.
+func MergeKustomizationFile(existing []byte, manifestFiles iter.Seq[string], stale []string, cfg *config.Kustomization) (string, []byte, error) {
+	file, err := parser.ParseBytes(existing, parser.ParseComments)
+	if err != nil {
+		return "", nil, fmt.Errorf("parsing existing kustomization.yaml: %w", err)
+	}
+
+	var body ast.Node
+	if len(file.Docs) > 0 {
+		body = file.Docs[0].Body
+	}
+
+	root, ok := body.(*ast.MappingNode)
+	if !ok {
+		if body != nil && body.Type() != ast.CommentType {
+			return "", nil, errors.New("parsing existing kustomization.yaml: not a mapping")
+		}
+
+		// the file has no fields yet, keep its comments
+		kustomizationPath, content, err := marshalKustomization(yaml.MapSlice{}, manifestFiles, stale, cfg)
+		if err != nil {
+			return "", nil, err
+		}
+
+		var separator []byte
+		if len(existing) > 0 && !bytes.HasSuffix(existing, []byte("\n")) {
+			separator = []byte("\n")
+		}
+
+		return kustomizationPath, slices.Concat(existing, separator, content), nil
+	}
+
+	if cfg == nil {
+		cfg = &config.Kustomization{}
+	}
+
+	if cfg.Namespace != "" {
+		if err := setNode(file, root, "namespace", cfg.Namespace); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if len(cfg.Labels) > 0 {
+		if err := setNode(file, root, "labels", labelsValue(cfg.Labels)); err != nil {
+			return "", nil, err
+		}
+	}
+
+	generated := slices.Sorted(manifestFiles)
+
+	resources, ok := sequenceNode(root, "resources")
+	if !ok {
+		if err := setNode(file, root, "resources", slices.Concat(generated, cfg.Resources)); err != nil {
+			return "", nil, err
+		}
+	} else {
+		removeEntries(resources, func(entry ast.Node) bool {
+			name, ok := entry.(*ast.StringNode)
+			return ok && (slices.Contains(stale, name.Value) || slices.Contains(generated, name.Value))
+		})
+
+		if err := appendEntries(resources, slices.Concat(generated, cfg.Resources), func(entry ast.Node, value string) bool {
+			name, ok := entry.(*ast.StringNode)
+			return ok && name.Value == value
+		}); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if len(cfg.Patches) > 0 {
+		patches, ok := sequenceNode(root, "patches")
+		if !ok {
+			if err := setNode(file, root, "patches", patchesValue(nil, cfg.Patches)); err != nil {
+				return "", nil, err
+			}
+		} else {
+			missing := slices.DeleteFunc(slices.Clone(cfg.Patches), func(patch string) bool {
+				return slices.ContainsFunc(patches.Values, func(entry ast.Node) bool {
+					var value any
+					return yaml.NodeToValue(entry, &value) == nil && patchPath(value) == patch
+				})
+			})
+
+			for _, entry := range patchesValue(nil, missing) {
+				node, err := yaml.ValueToNode(entry)
+				if err != nil {
+					return "", nil, fmt.Errorf("encoding patch: %w", err)
+				}
+
+				patches.Values = append(patches.Values, node)
+				patches.ValueHeadComments = append(patches.ValueHeadComments, nil)
+			}
+		}
+	}
+
+	return KustomizationFile, []byte(strings.TrimRight(file.String(), "\n") + "\n"), nil
+}
+
+// sequenceNode returns the block or flow sequence under key of root.
+func sequenceNode(root *ast.MappingNode, key string) (*ast.SequenceNode, bool) {
+	for _, value := range root.Values {
+		if value.Key.String() == key {
+			sequence, ok := value.Value.(*ast.SequenceNode)
+			return sequence, ok
+		}
+	}
+
+	return nil, false
+}
+
+// setNode sets key of root to value, replacing the existing value or adding
+// the key at the end of root.
+func setNode(file *ast.File, root *ast.MappingNode, key string, value any) error {
+	exists := slices.ContainsFunc(root.Values, func(item *ast.MappingValueNode) bool {
+		return item.Key.String() == key
+	})
 
-	kustomization := map[string]any{
-		"apiVersion": "kustomize.config.k8s.io/v1beta1",
-		"kind":       "Kustomization",
-		"resources":  slices.Sorted(manifestFiles),
+	if exists {
+		node, err := yaml.ValueToNode(value)
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", key, err)
+		}
+
+		path, err := yaml.PathString("$." + key)
+		if err != nil {
+			return err
+		}
+
+		if err := path.ReplaceWithNode(file, node); err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+
+		return nil
+	}
+
+	node, err := yaml.ValueToNode(yaml.MapSlice{{Key: key, Value: value}})
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
 	}
 
-	yamlData, err := yaml.Marshal(kustomization)
+	path, err := yaml.PathString("$")
+	if err != nil {
+		return err
+	}
+
+	if err := path.MergeFromNode(file, node); err != nil {
+		return fmt.Errorf("setting %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// removeEntries removes entries of sequence matching remove along with their comments.
+func removeEntries(sequence *ast.SequenceNode, remove func(entry ast.Node) bool) {
+	var values []ast.Node
+	var comments []*ast.CommentGroupNode
+
+	for i, entry := range sequence.Values {
+		if remove(entry) {
+			continue
+		}
+
+		values = append(values, entry)
+		if i < len(sequence.ValueHeadComments) {
+			comments = append(comments, sequence.ValueHeadComments[i])
+		}
+	}
+
+	sequence.Values = values
+	sequence.ValueHeadComments = comments
+}
+
+// appendEntries appends the values not yet in sequence to its end.
+func appendEntries(sequence *ast.SequenceNode, values []string, equal func(entry ast.Node, value string) bool) error {
+	for _, value := range values {
+		if slices.ContainsFunc(sequence.Values, func(entry ast.Node) bool { return equal(entry, value) }) {
+			continue
+		}
+
+		node, err := yaml.ValueToNode(value)
+		if err != nil {
+			return fmt.Errorf("encoding %s: %w", value, err)
+		}
+
+		sequence.Values = append(sequence.Values, node)
+		if len(sequence.ValueHeadComments) > 0 {
+			sequence.ValueHeadComments = append(sequence.ValueHeadComments, nil)
+		}
+	}
+
+	return nil
+}
+
+// labelsValue returns the labels field of a kustomization setting labels.
+func labelsValue(labels map[string]string) []any {
+	pairs := make(map[string]any, len(labels))
+	for key, value := range labels {
+		pairs[key] = value
+	}
+
+	return []any{yaml.MapSlice{{Key: "pairs", Value: pairs}}}
+}
+
+// patchesValue returns patches with an entry for every path not yet in patches.
+func patchesValue(patches []any, paths []string) []any {
+	for _, patch := range paths {
+		if !slices.ContainsFunc(patches, func(item any) bool { return patchPath(item) == patch }) {
+			patches = append(patches, yaml.MapSlice{{Key: "path", Value: patch}})
+		}
+	}
+
+	return patches
+}
+
+func marshalKustomization(kustomization yaml.MapSlice, manifestFiles iter.Seq[string], stale []string, cfg *config.Kustomization) (string, []byte, error) {
+	if cfg == nil {
+		cfg = &config.Kustomization{}
+	}
+
+	if cfg.Namespace != "" {
+		kustomization = setKey(kustomization, "namespace", cfg.Namespace)
+	}
+
+	if len(cfg.Labels) > 0 {
+		kustomization = setKey(kustomization, "labels", labelsValue(cfg.Labels))
+	}
+
+	generated := slices.Sorted(manifestFiles)
+
+	var resources []any
+	for _, resource := range listValue(kustomization, "resources") {
+		if name, ok := resource.(string); ok && (slices.Contains(stale, name) || slices.Contains(generated, name)) {
+			continue
+		}
+
+		resources = append(resources, resource)
+	}
+
+	for _, resource := range slices.Concat(generated, cfg.Resources) {
+		if !slices.Contains(resources, any(resource)) {
+			resources = append(resources, resource)
+		}
+	}
+
+	kustomization = setKey(kustomization, "resources", resources)
+
+	if len(cfg.Patches) > 0 {
+		kustomization = setKey(kustomization, "patches", patchesValue(listValue(kustomization, "patches"), cfg.Patches))
+	}
+
+	yamlData, err := manifest.Marshal(kustomization)
 	if err != nil {
 		return "", nil, fmt.Errorf("marshaling kustomization.yaml: %w", err)
 	}
 
-	return kustomizationPath, yamlData, nil
+	return KustomizationFile, yamlData, nil
+}
+
+func listValue(document yaml.MapSlice, key string) []any {
+	for _, item := range document {
+		if item.Key == key {
+			list, _ := item.Value.([]any)
+			return list
+		}
+	}
+
+	return nil
+}
+
+func setKey(document yaml.MapSlice, key string, value any) yaml.MapSlice {
+	for i, item := range document {
+		if item.Key == key {
+			document[i].Value = value
+			return document
+		}
+	}
+
+	return append(document, yaml.MapItem{Key: key, Value: value})
+}
+
+func patchPath(patch any) string {
+	switch patch := patch.(type) {
+	case map[string]any:
+		path, _ := patch["path"].(string)
+		return path
+
+	case yaml.MapSlice:
+		for _, item := range patch {
+			if item.Key == "path" {
+				path, _ := item.Value.(string)
+				return path
+			}
+		}
+	}
+
+	return ""
+}
+
+// HelmChart identifies a Helm chart inflated by a kustomization.
+type HelmChart struct {
+	Name    string `yaml:"name"`
+	Version string `yaml:"version"`
+	Repo    string `yaml:"repo"`
+}
+
+// String returns the chart name and version, e.g. "ingress-nginx 4.10.0".
+func (c HelmChart) String() string {
+	if c.Version == "" {
+		return c.Name
+	}
+
+	return c.Name + " " + c.Version
+}
+
+// ReadHelmCharts returns the Helm charts listed in helmCharts of the kustomization.yaml in sourceDir.
+func ReadHelmCharts(afs afero.Fs, sourceDir string) ([]HelmChart, error) {
+	kustomizationPath := path.Join(sourceDir, "kustomization.yaml")
+
+	data, err := afero.ReadFile(afs, kustomizationPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", kustomizationPath, err)
+	}
+
+	var kustomization struct {
+		HelmCharts []HelmChart `yaml:"helmCharts"`
+	}
+
+	if err := yaml.Unmarshal(data, &kustomization); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", kustomizationPath, err)
+	}
+
+	return kustomization.HelmCharts, nil
 }
 
 // VerifyHasKustomizationFile checks if the source directory contains a kustomization.yaml file.
@@ -0,0 +1,123 @@
+package kustomize_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/kustomize"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func TestGenerateKustomizationFile(t *testing.T) {
+	path, content, err := kustomize.GenerateKustomizationFile(slices.Values([]string{"b.yaml", "a.yaml"}), &config.Kustomization{
+		Namespace: "web",
+		Labels:    map[string]string{"team": "web"},
+		Resources: []string{"extra.yaml"},
+		Patches:   []string{"patch.yaml"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "kustomization.yaml", path)
+	assert.Equal(t, `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: web
+labels:
+- pairs:
+    team: web
+resources:
+- a.yaml
+- b.yaml
+- extra.yaml
+patches:
+- path: patch.yaml
+`, string(content))
+}
+
+func TestMergeKustomizationFile(t *testing.T) {
+	existing := `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ../base
+- removed.yaml
+- kept.yaml
+patches:
+- path: patch.yaml
+`
+
+	_, content, err := kustomize.MergeKustomizationFile([]byte(existing), slices.Values([]string{"kept.yaml", "added.yaml"}), []string{"removed.yaml", "kept.yaml"}, &config.Kustomization{
+		Merge:   true,
+		Patches: []string{"patch.yaml"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ../base
+- added.yaml
+- kept.yaml
+patches:
+- path: patch.yaml
+`, string(content))
+}
+
+func TestMergeKustomizationFileKeepsFormatting(t *testing.T) {
+	existing := `# Deployed by Flux, do not rename.
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: old
+resources:
+  # shared base
+  - ../base
+  - removed.yaml # generated
+images:
+  - name: nginx
+    newTag: "1.25" # pinned until the next upgrade
+patches:
+  - path: patch.yaml
+    target:
+      kind: Deployment
+`
+
+	_, content, err := kustomize.MergeKustomizationFile([]byte(existing), slices.Values([]string{"added.yaml"}), []string{"removed.yaml"}, &config.Kustomization{
+		Merge:     true,
+		Namespace: "web",
+		Labels:    map[string]string{"team": "web"},
+		Patches:   []string{"patch.yaml", "extra-patch.yaml"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, `# Deployed by Flux, do not rename.
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: web
+resources:
+  # shared base
+  - ../base
+  - added.yaml
+images:
+  - name: nginx
+    newTag: "1.25" # pinned until the next upgrade
+patches:
+  - path: patch.yaml
+    target:
+      kind: Deployment
+  - path: extra-patch.yaml
+labels:
+- pairs:
+    team: web
+`, string(content))
+}
+
+func TestMergeKustomizationFileEmpty(t *testing.T) {
+	_, content, err := kustomize.MergeKustomizationFile([]byte("# nothing yet\n"), slices.Values([]string{"a.yaml"}), nil, &config.Kustomization{Merge: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, `# nothing yet
+resources:
+- a.yaml
+`, string(content))
+}
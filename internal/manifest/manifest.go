@@ -1,13 +1,13 @@
 package manifest
 
 import (
-	"bytes"
-	"errors"
 	"fmt"
-	"io"
 	"slices"
+	"strings"
 
 	yaml "github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
 
 	"github.com/artuross/kubesource/pkg/config"
 )
@@ -20,25 +20,79 @@ type ParsedDocument struct {
 	Document yaml.MapSlice
 }
 
-func (d ParsedDocument) Matches(filter *config.Filter) bool {
-	if filter == nil {
-		return true
+// NewParsedDocument returns a ParsedDocument with metadata extracted from document.
+func NewParsedDocument(document yaml.MapSlice) ParsedDocument {
+	return ParsedDocument{
+		Metadata: extractSelector(document),
+		Document: document,
 	}
+}
+
+// Clone returns a deep copy of the document, so it can be modified without
+// affecting documents shared with other targets.
+func (d ParsedDocument) Clone() ParsedDocument {
+	document, _ := CloneValue(d.Document).(yaml.MapSlice)
+
+	return NewParsedDocument(document)
+}
 
-	included := slices.ContainsFunc(filter.Include, d.matchesSelector)
-	if len(filter.Include) == 0 {
-		included = true
+// String returns a short identifier of the document, e.g. "apps/v1 Deployment default/web".
+// Documents with neither name nor generateName are shown as "<unnamed>", and
+// a missing apiVersion or kind is left out.
+func (d ParsedDocument) String() string {
+	var name, namespace string
+	if d.Metadata.Metadata != nil {
+		name = d.Metadata.Metadata.Name
+		namespace = d.Metadata.Metadata.Namespace
+
+		if name == "" {
+			name = d.Metadata.Metadata.GenerateName
+		}
 	}
 
-	if !included {
-		return false
+	if name == "" {
+		name = "<unnamed>"
 	}
 
-	if len(filter.Exclude) == 0 {
-		return true
+	if namespace != "" {
+		name = namespace + "/" + name
 	}
 
-	return !slices.ContainsFunc(filter.Exclude, d.matchesSelector)
+	parts := []string{d.Metadata.APIVersion, d.Metadata.Kind, name}
+
+	return strings.Join(slices.DeleteFunc(parts, func(part string) bool { return part == "" }), " ")
+}
+
+// MatchesSelector reports whether the document matches selector.
+func (d ParsedDocument) MatchesSelector(selector config.Selector) bool {
+	return d.matchesSelector(selector)
+}
+
+// MatchingSelector returns the index of the first selector that matches the document.
+func (d ParsedDocument) MatchingSelector(selectors []config.Selector) (int, bool) {
+	index := slices.IndexFunc(selectors, d.matchesSelector)
+
+	return index, index >= 0
+}
+
+// DropDocuments splits documents into those that match none of the selectors
+// and those that match at least one.
+func DropDocuments(documents []ParsedDocument, selectors []config.Selector) ([]ParsedDocument, []ParsedDocument) {
+	if len(selectors) == 0 {
+		return documents, nil
+	}
+
+	var kept, dropped []ParsedDocument
+	for _, document := range documents {
+		if _, ok := document.MatchingSelector(selectors); ok {
+			dropped = append(dropped, document)
+			continue
+		}
+
+		kept = append(kept, document)
+	}
+
+	return kept, dropped
 }
 
 func (d ParsedDocument) matchesSelector(selector config.Selector) bool {
@@ -50,20 +104,37 @@ func (d ParsedDocument) matchesSelector(selector config.Selector) bool {
 		return false
 	}
 
+	if selector.Group != "" && !matchesGroup(selector.Group, d.Metadata.Group) {
+		return false
+	}
+
+	if selector.Version != "" && selector.Version != d.Metadata.Version {
+		return false
+	}
+
 	if selector.Metadata == nil {
 		return true
 	}
 
-	if selector.Metadata.Name != "" && selector.Metadata.Name != d.Metadata.Metadata.Name {
+	metadata := d.Metadata.Metadata
+	if metadata == nil {
+		metadata = &config.MetadataSelector{}
+	}
+
+	if selector.Metadata.Name != "" && selector.Metadata.Name != metadata.Name {
 		return false
 	}
 
-	if selector.Metadata.Namespace != "" && selector.Metadata.Namespace != d.Metadata.Metadata.Namespace {
+	if selector.Metadata.GenerateName != "" && selector.Metadata.GenerateName != metadata.GenerateName {
+		return false
+	}
+
+	if selector.Metadata.Namespace != "" && selector.Metadata.Namespace != metadata.Namespace {
 		return false
 	}
 
 	for selectorLabel, selectorLabelValue := range selector.Metadata.Labels {
-		documentLabel, ok := d.Metadata.Metadata.Labels[selectorLabel]
+		documentLabel, ok := metadata.Labels[selectorLabel]
 		if !ok {
 			return false
 		}
@@ -76,39 +147,198 @@ func (d ParsedDocument) matchesSelector(selector config.Selector) bool {
 	return true
 }
 
+// SplitAPIVersion splits apiVersion into its group and version. Resources from
+// the core API group (for example "v1") have an empty group.
+func SplitAPIVersion(apiVersion string) (string, string) {
+	group, version, found := strings.Cut(apiVersion, "/")
+	if !found {
+		return "", apiVersion
+	}
+
+	return group, version
+}
+
 // ParseDocuments parses a multi-document or single-document YAML payload and
 // returns a slice of ParsedDocument. Non-mapping (non-object) documents or empty
 // documents are skipped, since Kubernetes manifests are expected to be mappings.
+// List documents, such as v1/List or apps/v1/DeploymentList, are replaced by
+// their items.
 //
 // Order of keys in each document is preserved via yaml.MapSlice.
 func ParseDocuments(content []byte) ([]ParsedDocument, error) {
-	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	nodes, err := parseNodes(content)
+	if err != nil {
+		return nil, err
+	}
 
 	var documents []ParsedDocument
-	for {
-		var document yaml.MapSlice
-		err := decoder.Decode(&document)
-		if errors.Is(err, io.EOF) {
-			break
+	for _, node := range nodes {
+		documents, err = appendDocuments(documents, node)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return documents, nil
+}
+
+// appendDocuments decodes node and appends it to documents, or its items if it is a list.
+func appendDocuments(documents []ParsedDocument, node ast.Node) ([]ParsedDocument, error) {
+	document, err := decodeDocument(node)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(document) == 0 {
+		return documents, nil
+	}
+
+	pd := NewParsedDocument(document)
+	if !isList(pd) {
+		return append(documents, pd), nil
+	}
+
+	items, ok := listItems(node)
+	if !ok {
+		return nil, fmt.Errorf("decoding %s: items is not a list", pd)
+	}
+
+	for _, item := range items {
+		documents, err = appendDocuments(documents, item)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return documents, nil
+}
+
+// isList reports whether pd is a list of resources, such as v1/List or apps/v1/DeploymentList.
+func isList(pd ParsedDocument) bool {
+	if !strings.HasSuffix(pd.Metadata.Kind, "List") {
+		return false
+	}
+
+	for _, item := range pd.Document {
+		if item.Key == "items" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// listItems returns nodes of the items of a list document.
+func listItems(node ast.Node) ([]ast.Node, bool) {
+	var values []*ast.MappingValueNode
+
+	switch node := node.(type) {
+	case *ast.MappingNode:
+		values = node.Values
+	case *ast.MappingValueNode:
+		values = []*ast.MappingValueNode{node}
+	}
+
+	for _, value := range values {
+		key, ok := value.Key.(*ast.StringNode)
+		if !ok || key.Value != "items" {
+			continue
 		}
 
+		switch items := value.Value.(type) {
+		case *ast.SequenceNode:
+			return items.Values, true
+		case *ast.NullNode:
+			return nil, true
+		}
+
+		return nil, false
+	}
+
+	return nil, false
+}
+
+// decodeDocuments decodes all non-empty documents in content.
+func decodeDocuments(content []byte) ([]yaml.MapSlice, error) {
+	nodes, err := parseNodes(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []yaml.MapSlice
+	for _, node := range nodes {
+		document, err := decodeDocument(node)
 		if err != nil {
-			return nil, fmt.Errorf("decoding YAML document: %w", err)
+			return nil, err
 		}
 
 		if len(document) == 0 {
 			continue
 		}
 
-		documents = append(documents, ParsedDocument{
-			Metadata: extractSelector(document),
-			Document: document,
-		})
+		documents = append(documents, document)
 	}
 
 	return documents, nil
 }
 
+// parseNodes returns the bodies of non-empty documents in content.
+func parseNodes(content []byte) ([]ast.Node, error) {
+	file, err := parser.ParseBytes(content, 0)
+	if err != nil {
+		return nil, fmt.Errorf("decoding YAML document: %w", err)
+	}
+
+	var nodes []ast.Node
+	for _, doc := range file.Docs {
+		if doc.Body != nil {
+			nodes = append(nodes, doc.Body)
+		}
+	}
+
+	return nodes, nil
+}
+
+func decodeDocument(node ast.Node) (yaml.MapSlice, error) {
+	var document yaml.MapSlice
+	if err := yaml.NodeToValue(node, &document); err != nil {
+		return nil, fmt.Errorf("decoding YAML document: %w", err)
+	}
+
+	return fixExponentFloats(node, document).(yaml.MapSlice), nil
+}
+
+// CloneValue returns a deep copy of a decoded YAML value.
+func CloneValue(value any) any {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		clone := make(yaml.MapSlice, len(value))
+		for i, item := range value {
+			clone[i] = yaml.MapItem{Key: item.Key, Value: CloneValue(item.Value)}
+		}
+
+		return clone
+
+	case map[string]any:
+		clone := make(map[string]any, len(value))
+		for key, item := range value {
+			clone[key] = CloneValue(item)
+		}
+
+		return clone
+
+	case []any:
+		clone := make([]any, len(value))
+		for i, item := range value {
+			clone[i] = CloneValue(item)
+		}
+
+		return clone
+	}
+
+	return value
+}
+
 func extractMapSliceString(document yaml.MapSlice, key string) string {
 	value, ok := getMapSliceValue(document, key)
 	if !ok {
@@ -130,24 +360,31 @@ func extractMetadata(document yaml.MapSlice) *config.MetadataSelector {
 	}
 
 	name := getNestedString(metadata, "name")
+	generateName := getNestedString(metadata, "generateName")
 	namespace := getNestedString(metadata, "namespace")
 	labels := getNestedLabels(metadata)
 
-	if name == "" && namespace == "" && len(labels) == 0 {
+	if name == "" && generateName == "" && namespace == "" && len(labels) == 0 {
 		return nil
 	}
 
 	return &config.MetadataSelector{
-		Name:      name,
-		Namespace: namespace,
-		Labels:    labels,
+		Name:         name,
+		GenerateName: generateName,
+		Namespace:    namespace,
+		Labels:       labels,
 	}
 }
 
 func extractSelector(document yaml.MapSlice) config.Selector {
+	apiVersion := extractMapSliceString(document, "apiVersion")
+	group, version := SplitAPIVersion(apiVersion)
+
 	return config.Selector{
 		Kind:       extractMapSliceString(document, "kind"),
-		APIVersion: extractMapSliceString(document, "apiVersion"),
+		APIVersion: apiVersion,
+		Group:      group,
+		Version:    version,
 		Metadata:   extractMetadata(document),
 	}
 }
@@ -167,6 +404,14 @@ func getMapSliceValue(document yaml.MapSlice, key string) (any, bool) {
 	return nil, false
 }
 
+func matchesGroup(selectorGroup, documentGroup string) bool {
+	if selectorGroup == config.CoreGroup {
+		return documentGroup == ""
+	}
+
+	return selectorGroup == documentGroup
+}
+
 func getNestedLabels(metadata any) map[string]string {
 	labels := map[string]string{}
 
@@ -214,33 +459,28 @@ func getNestedLabels(metadata any) map[string]string {
 }
 
 func getNestedString(document any, key string) string {
-	switch document := document.(type) {
-	case yaml.MapSlice:
-		value, ok := getMapSliceValue(document, key)
-		if !ok {
-			return ""
-		}
+	value, ok := getNestedValue(document, key)
+	if !ok {
+		return ""
+	}
 
-		str, ok := value.(string)
-		if !ok {
-			return ""
-		}
+	str, ok := value.(string)
+	if !ok {
+		return ""
+	}
 
-		return str
+	return str
+}
+
+func getNestedValue(document any, key string) (any, bool) {
+	switch document := document.(type) {
+	case yaml.MapSlice:
+		return getMapSliceValue(document, key)
 
 	case map[string]any:
 		value, ok := document[key]
-		if !ok {
-			return ""
-		}
-
-		str, ok := value.(string)
-		if !ok {
-			return ""
-		}
-
-		return str
+		return value, ok
 	}
 
-	return ""
+	return nil, false
 }
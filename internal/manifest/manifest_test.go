@@ -0,0 +1,409 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func TestSplitAPIVersion(t *testing.T) {
+	tests := []struct {
+		apiVersion      string
+		expectedGroup   string
+		expectedVersion string
+	}{
+		{apiVersion: "v1", expectedGroup: "", expectedVersion: "v1"},
+		{apiVersion: "apps/v1", expectedGroup: "apps", expectedVersion: "v1"},
+		{apiVersion: "cert-manager.io/v1", expectedGroup: "cert-manager.io", expectedVersion: "v1"},
+		{apiVersion: "", expectedGroup: "", expectedVersion: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.apiVersion, func(t *testing.T) {
+			group, version := manifest.SplitAPIVersion(tt.apiVersion)
+
+			assert.Equal(t, tt.expectedGroup, group)
+			assert.Equal(t, tt.expectedVersion, version)
+		})
+	}
+}
+
+func TestParsedDocumentMatches(t *testing.T) {
+	documents, err := manifest.ParseDocuments([]byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+  namespace: default
+---
+apiVersion: cert-manager.io/v2
+kind: Certificate
+metadata:
+  name: tls
+  labels:
+    app: web
+`))
+	require.NoError(t, err)
+	require.Len(t, documents, 2)
+
+	secret, certificate := documents[0], documents[1]
+
+	tests := []struct {
+		name           string
+		selector       config.Selector
+		matchesSecret  bool
+		matchesCertMgr bool
+	}{
+		{
+			name:           "empty selector",
+			selector:       config.Selector{},
+			matchesSecret:  true,
+			matchesCertMgr: true,
+		},
+		{
+			name:           "full apiVersion",
+			selector:       config.Selector{APIVersion: "cert-manager.io/v1"},
+			matchesSecret:  false,
+			matchesCertMgr: false,
+		},
+		{
+			name:           "group independent of version",
+			selector:       config.Selector{Group: "cert-manager.io"},
+			matchesSecret:  false,
+			matchesCertMgr: true,
+		},
+		{
+			name:           "group and kind",
+			selector:       config.Selector{Group: "cert-manager.io", Kind: "Certificate"},
+			matchesSecret:  false,
+			matchesCertMgr: true,
+		},
+		{
+			name:           "core group",
+			selector:       config.Selector{Group: config.CoreGroup},
+			matchesSecret:  true,
+			matchesCertMgr: false,
+		},
+		{
+			name:           "version only",
+			selector:       config.Selector{Version: "v1"},
+			matchesSecret:  true,
+			matchesCertMgr: false,
+		},
+		{
+			name:           "labels",
+			selector:       config.Selector{Metadata: &config.MetadataSelector{Labels: map[string]string{"app": "web"}}},
+			matchesSecret:  false,
+			matchesCertMgr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matchesSecret, secret.MatchesSelector(tt.selector))
+			assert.Equal(t, tt.matchesCertMgr, certificate.MatchesSelector(tt.selector))
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	documents, err := manifest.ParseDocuments([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+`))
+	require.NoError(t, err)
+
+	matcher := manifest.NewMatcher([]config.TargetFilter{
+		{
+			Path: "filters.no-secrets",
+			Filter: &config.Filter{
+				Exclude: []config.Selector{{Kind: "Secret"}, {Kind: "Namespace"}},
+			},
+		},
+		{
+			Path: "filter",
+			Filter: &config.Filter{
+				Include: []config.Selector{{Group: config.CoreGroup}},
+			},
+		},
+	})
+
+	assert.True(t, matcher.Matches(documents[0]))
+	assert.False(t, matcher.Matches(documents[1]))
+
+	assert.Equal(t, []manifest.SelectorHit{
+		{Path: "filters.no-secrets.exclude[1]", Selector: config.Selector{Kind: "Namespace"}, Hits: 0},
+	}, matcher.UnmatchedSelectors())
+
+	assert.Equal(t, []manifest.SelectorHit{
+		{Path: "filters.no-secrets.exclude[0]", Selector: config.Selector{Kind: "Secret"}, Hits: 1},
+		{Path: "filters.no-secrets.exclude[1]", Selector: config.Selector{Kind: "Namespace"}, Hits: 0},
+		{Path: "filter.include[0]", Selector: config.Selector{Group: config.CoreGroup}, Hits: 2},
+	}, matcher.Selectors())
+}
+
+func TestMatcherExplain(t *testing.T) {
+	documents, err := manifest.ParseDocuments([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`))
+	require.NoError(t, err)
+
+	matcher := manifest.NewMatcher([]config.TargetFilter{
+		{
+			Path: "filter",
+			Filter: &config.Filter{
+				Include: []config.Selector{{Group: config.CoreGroup}},
+				Exclude: []config.Selector{{Kind: "Secret"}},
+			},
+		},
+	})
+
+	assert.Equal(t, "included by filter.include[0] {group: core}", matcher.Explain(documents[0]).String())
+	assert.Equal(t, "excluded by filter.exclude[0] {kind: Secret}", matcher.Explain(documents[1]).String())
+	assert.Equal(t, "excluded, no selector in filter.include matched", matcher.Explain(documents[2]).String())
+	assert.Equal(t, "included, no filter applies", manifest.NewMatcher(nil).Explain(documents[0]).String())
+
+	excludeOnly := manifest.NewMatcher([]config.TargetFilter{
+		{Path: "filter", Filter: &config.Filter{Exclude: []config.Selector{{Kind: "Secret"}}}},
+	})
+
+	assert.Equal(t, "included, no selector in filter.exclude matched", excludeOnly.Explain(documents[2]).String())
+	assert.Equal(t, "excluded by filter.exclude[0] {kind: Secret}", excludeOnly.Explain(documents[1]).String())
+
+	assert.Len(t, matcher.UnmatchedSelectors(), 2, "Explain must not record hits")
+}
+
+func TestDropDocuments(t *testing.T) {
+	documents, err := manifest.ParseDocuments([]byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`))
+	require.NoError(t, err)
+
+	kept, dropped := manifest.DropDocuments(documents, []config.Selector{{Kind: "Namespace"}})
+	require.Len(t, kept, 1)
+	require.Len(t, dropped, 1)
+	assert.Equal(t, "ConfigMap", kept[0].Metadata.Kind)
+	assert.Equal(t, "Namespace", dropped[0].Metadata.Kind)
+
+	kept, dropped = manifest.DropDocuments(documents, nil)
+	assert.Equal(t, documents, kept)
+	assert.Empty(t, dropped)
+}
+
+func TestMarshal(t *testing.T) {
+	input := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  enabled: "on"
+  mode: "0755"
+  ratio: "1e3"
+  empty: ""
+  script: |
+    #!/bin/sh
+    echo "hello"
+  indented: "  first\nsecond"
+replicas: 1e3
+`
+
+	expected := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  empty: ""
+  enabled: "on"
+  indented: "  first\nsecond"
+  mode: "0755"
+  ratio: "1e3"
+  script: |
+    #!/bin/sh
+    echo "hello"
+replicas: 1000.0
+`
+
+	documents, err := manifest.ParseDocuments([]byte(input))
+	require.NoError(t, err)
+	require.Len(t, documents, 1)
+
+	content, err := manifest.Marshal(documents[0].Document)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(content))
+
+	reparsed, err := manifest.ParseDocuments(content)
+	require.NoError(t, err)
+	require.Len(t, reparsed, 1)
+	assert.Equal(t, documents[0].Document, reparsed[0].Document)
+}
+
+func TestParseDocumentsExpandsLists(t *testing.T) {
+	input := `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: first
+    namespace: default
+- apiVersion: apps/v1
+  kind: DeploymentList
+  items:
+  - apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: web
+---
+apiVersion: v1
+kind: ServiceList
+items: []
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+
+	documents, err := manifest.ParseDocuments([]byte(input))
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(documents))
+	for _, document := range documents {
+		names = append(names, document.String())
+	}
+
+	assert.Equal(t, []string{
+		"v1 ConfigMap default/first",
+		"apps/v1 Deployment web",
+		"v1 Service web",
+	}, names)
+	assert.Equal(t, "apiVersion", documents[0].Document[0].Key)
+}
+
+func TestValidateMetadata(t *testing.T) {
+	input := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: valid
+  labels:
+    app: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: 123
+  labels:
+    version: 1.5
+    enabled: true
+  annotations: [a]
+---
+apiVersion: v1
+metadata:
+  namespace: default
+---
+kind: Service
+metadata: broken
+`
+
+	documents, err := manifest.ParseDocuments([]byte(input))
+	require.NoError(t, err)
+
+	issues := manifest.ValidateMetadata(documents)
+
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+
+	assert.Equal(t, []string{
+		`document 1 (apps/v1 Deployment <unnamed>): metadata.name: expected a string, got 123`,
+		`document 1 (apps/v1 Deployment <unnamed>): metadata.labels[enabled]: expected a string, got true`,
+		`document 1 (apps/v1 Deployment <unnamed>): metadata.labels[version]: expected a string, got 1.5`,
+		`document 1 (apps/v1 Deployment <unnamed>): metadata.annotations: expected a mapping, got a list`,
+		`document 2 (v1 default/<unnamed>): kind: is missing`,
+		`document 3 (Service <unnamed>): apiVersion: is missing`,
+		`document 3 (Service <unnamed>): metadata: expected a mapping, got "broken"`,
+	}, messages)
+	assert.Equal(t, 1.5, issues[2].Value)
+}
+
+func TestParsedDocumentGenerateName(t *testing.T) {
+	documents, err := manifest.ParseDocuments([]byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  generateName: migrate-
+  namespace: default
+---
+apiVersion: v1
+kind: ConfigMap
+data:
+  key: value
+`))
+	require.NoError(t, err)
+	require.Len(t, documents, 2)
+
+	job, nameless := documents[0], documents[1]
+
+	assert.Equal(t, "batch/v1 Job default/migrate-", job.String())
+	assert.Equal(t, "v1 ConfigMap <unnamed>", nameless.String())
+	assert.True(t, job.MatchesSelector(config.Selector{Metadata: &config.MetadataSelector{GenerateName: "migrate-"}}))
+	assert.False(t, job.MatchesSelector(config.Selector{Metadata: &config.MetadataSelector{Name: "migrate-"}}))
+	assert.False(t, nameless.MatchesSelector(config.Selector{Metadata: &config.MetadataSelector{Name: "settings"}}))
+}
+
+func TestMarshalJSON(t *testing.T) {
+	documents, err := manifest.ParseDocuments([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  enabled: "on"
+  script: |
+    echo "hello"
+`))
+	require.NoError(t, err)
+
+	content, err := manifest.MarshalJSON(documents[0].Document)
+	require.NoError(t, err)
+
+	assert.Equal(t, `{
+  "apiVersion": "v1",
+  "kind": "ConfigMap",
+  "metadata": {
+    "name": "settings"
+  },
+  "data": {
+    "enabled": "on",
+    "script": "echo \"hello\"\n"
+  }
+}
+`, string(content))
+}
@@ -0,0 +1,311 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/printer"
+	"github.com/goccy/go-yaml/token"
+)
+
+// ambiguousScalarPatterns match plain scalars that a YAML 1.1 or YAML 1.2
+// parser resolves to a type other than string: numbers in any base, with
+// exponents, underscores or as sexagesimals, infinities, NaN and timestamps.
+var ambiguousScalarPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9][0-9_]*(\.[0-9_]*)?)([eE][-+]?[0-9]+)?$`),
+	regexp.MustCompile(`^[-+]?0([xX][0-9a-fA-F_]+|[oO]?[0-7_]+|[bB][01_]+)$`),
+	regexp.MustCompile(`^[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+(\.[0-9_]*)?$`),
+	regexp.MustCompile(`^[-+]?\.(inf|Inf|INF)$`),
+	regexp.MustCompile(`^\.(nan|NaN|NAN)$`),
+	regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}([Tt ].*)?$`),
+}
+
+// ambiguousScalarWords are plain scalars resolved to null or booleans by YAML 1.1
+// or YAML 1.2 parsers, or carrying a special meaning in YAML 1.1. Compared case-insensitively.
+var ambiguousScalarWords = map[string]struct{}{
+	"":      {},
+	"~":     {},
+	"null":  {},
+	"true":  {},
+	"false": {},
+	"y":     {},
+	"n":     {},
+	"yes":   {},
+	"no":    {},
+	"on":    {},
+	"off":   {},
+	"<<":    {},
+	"=":     {},
+}
+
+// exponentFloatPattern matches YAML 1.2 floats with an exponent and no decimal
+// point, such as "1e3", which the YAML decoder reads as strings.
+var exponentFloatPattern = regexp.MustCompile(`^[-+]?[0-9]+[eE][-+]?[0-9]+$`)
+
+// ErrRoundTrip is returned when an encoded document does not decode to the original document.
+var ErrRoundTrip = errors.New("encoded document does not decode to the original document")
+
+// Marshal encodes a document as YAML.
+//
+// Multi-line strings are written as literal block scalars and strings that a
+// YAML 1.1 or YAML 1.2 parser could read as another type, such as "on", "0755"
+// or "1e3", are double-quoted. Multi-line strings that a literal block cannot
+// represent, such as those starting with spaces, are double-quoted as well.
+// The output is decoded again and ErrRoundTrip is returned if it differs from
+// the document.
+func Marshal(document yaml.MapSlice) ([]byte, error) {
+	content, err := encode(document, quotingVisitor{checkLiterals: true})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyRoundTrip(document, content); err != nil {
+		return nil, err
+	}
+
+	return content, nil
+}
+
+// MarshalJSON encodes a document as indented JSON, keeping the order of keys.
+// The output is decoded again and ErrRoundTrip is returned if it differs from
+// the document.
+func MarshalJSON(document yaml.MapSlice) ([]byte, error) {
+	content, err := yaml.MarshalWithOptions(document, yaml.JSON())
+	if err != nil {
+		return nil, err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, bytes.TrimSpace(content), "", "  "); err != nil {
+		return nil, fmt.Errorf("encoding JSON: %w", err)
+	}
+
+	indented.WriteByte('\n')
+
+	if err := verifyRoundTrip(document, indented.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return indented.Bytes(), nil
+}
+
+func encode(document yaml.MapSlice, visitor quotingVisitor) ([]byte, error) {
+	node, err := yaml.ValueToNode(document, yaml.UseLiteralStyleIfMultiline(true))
+	if err != nil {
+		return nil, err
+	}
+
+	ast.Walk(visitor, node)
+
+	var p printer.Printer
+
+	return p.PrintNode(node), nil
+}
+
+// quotingVisitor double-quotes ambiguous plain strings and, if checkLiterals
+// is set, multi-line strings that do not survive a round trip as literal blocks.
+type quotingVisitor struct {
+	checkLiterals bool
+}
+
+func (v quotingVisitor) Visit(node ast.Node) ast.Visitor {
+	str, ok := node.(*ast.StringNode)
+	if !ok || str.Token.Type == token.DoubleQuoteType || str.Token.Type == token.SingleQuoteType {
+		return v
+	}
+
+	if strings.ContainsAny(str.Value, "\r\n") {
+		if v.checkLiterals && !literalRoundTrips(str.Value) {
+			str.Token.Type = token.DoubleQuoteType
+		}
+	} else if isAmbiguousScalar(str.Value) {
+		str.Token.Type = token.DoubleQuoteType
+	}
+
+	return v
+}
+
+// literalRoundTrips reports whether value decodes back to itself when encoded
+// as a literal block scalar.
+func literalRoundTrips(value string) bool {
+	document := yaml.MapSlice{{Key: "value", Value: value}}
+
+	content, err := encode(document, quotingVisitor{})
+	if err != nil {
+		return false
+	}
+
+	return verifyRoundTrip(document, content) == nil
+}
+
+func isAmbiguousScalar(value string) bool {
+	if _, ok := ambiguousScalarWords[strings.ToLower(value)]; ok {
+		return true
+	}
+
+	for _, pattern := range ambiguousScalarPatterns {
+		if pattern.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyRoundTrip decodes content and compares it with document.
+func verifyRoundTrip(document yaml.MapSlice, content []byte) error {
+	documents, err := decodeDocuments(content)
+	if err != nil {
+		return errors.Join(ErrRoundTrip, err)
+	}
+
+	if len(documents) != 1 || !reflect.DeepEqual(canonicalValue(document), canonicalValue(documents[0])) {
+		return ErrRoundTrip
+	}
+
+	return nil
+}
+
+// canonicalValue converts a decoded value to a form suitable for comparison:
+// mappings become map[string]any and numbers int64, uint64 or float64.
+func canonicalValue(value any) any {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		canonical := make(map[string]any, len(value))
+		for _, item := range value {
+			canonical[keyString(item.Key)] = canonicalValue(item.Value)
+		}
+
+		return canonical
+
+	case map[string]any:
+		canonical := make(map[string]any, len(value))
+		for key, item := range value {
+			canonical[key] = canonicalValue(item)
+		}
+
+		return canonical
+
+	case []any:
+		canonical := make([]any, len(value))
+		for i, item := range value {
+			canonical[i] = canonicalValue(item)
+		}
+
+		return canonical
+
+	case int:
+		return int64(value)
+	case int32:
+		return int64(value)
+	case uint32:
+		return int64(value)
+	case uint64:
+		if value <= math.MaxInt64 {
+			return int64(value)
+		}
+
+		return value
+	case float32:
+		return canonicalValue(float64(value))
+	case float64:
+		// NaN never equals itself
+		if math.IsNaN(value) {
+			return "NaN"
+		}
+
+		return value
+	}
+
+	return value
+}
+
+func keyString(key any) string {
+	if str, ok := key.(string); ok {
+		return str
+	}
+
+	return fmt.Sprint(key)
+}
+
+// fixExponentFloats converts plain scalars like "1e3", which YAML 1.2 defines
+// as floats but the YAML decoder reads as strings, to float64. node is the
+// syntax tree value was decoded from; quoted strings are left untouched.
+func fixExponentFloats(node ast.Node, value any) any {
+	switch node := node.(type) {
+	case *ast.TagNode:
+		if node.Start != nil && node.Start.Value != "" && node.Start.Value != "!!float" {
+			return value
+		}
+
+		return fixExponentFloats(node.Value, value)
+
+	case *ast.AnchorNode:
+		return fixExponentFloats(node.Value, value)
+
+	case *ast.StringNode:
+		str, ok := value.(string)
+		if !ok || node.Token.Type != token.StringType || !exponentFloatPattern.MatchString(str) {
+			return value
+		}
+
+		float, err := strconv.ParseFloat(str, 64)
+		if err != nil {
+			return value
+		}
+
+		return float
+
+	case *ast.MappingNode:
+		for _, item := range node.Values {
+			value = fixExponentFloatsInMapping(item, value)
+		}
+
+	case *ast.MappingValueNode:
+		value = fixExponentFloatsInMapping(node, value)
+
+	case *ast.SequenceNode:
+		list, ok := value.([]any)
+		if !ok || len(list) != len(node.Values) {
+			return value
+		}
+
+		for i, item := range node.Values {
+			list[i] = fixExponentFloats(item, list[i])
+		}
+	}
+
+	return value
+}
+
+func fixExponentFloatsInMapping(node *ast.MappingValueNode, value any) any {
+	key, ok := node.Key.(*ast.StringNode)
+	if !ok {
+		return value
+	}
+
+	switch mapping := value.(type) {
+	case yaml.MapSlice:
+		for i, item := range mapping {
+			if itemKey, ok := item.Key.(string); ok && itemKey == key.Value {
+				mapping[i].Value = fixExponentFloats(node.Value, item.Value)
+			}
+		}
+
+	case map[string]any:
+		if item, ok := mapping[key.Value]; ok {
+			mapping[key.Value] = fixExponentFloats(node.Value, item)
+		}
+	}
+
+	return value
+}
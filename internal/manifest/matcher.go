@@ -0,0 +1,207 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+// Matcher evaluates documents against the filters of a target and records how
+// many documents matched each selector.
+//
+// Hits are counted against every evaluated document, independently of whether
+// the document was finally written, so a selector is reported as unmatched only
+// when it matches nothing in the rendered source.
+type Matcher struct {
+	filters []config.TargetFilter
+	hits    []selectorHits
+}
+
+type selectorHits struct {
+	include []int
+	exclude []int
+}
+
+// SelectorHit is a selector along with its declaration path and number of matched documents.
+type SelectorHit struct {
+	Path     string
+	Selector config.Selector
+	Hits     int
+}
+
+// Decision describes why a document was or was not selected by a Matcher.
+//
+// Path identifies the selector that decided the outcome, e.g. "filter.exclude[0]".
+// When a document is rejected because none of the include selectors of a filter
+// matched, Path points at the include list, e.g. "filters.crds-only.include",
+// and Selector is nil. Likewise, a document kept only because none of the
+// exclude selectors matched points at the first exclude list checked, e.g.
+// "filter.exclude". Path is empty when no filter restricts the document.
+type Decision struct {
+	Included bool
+	Path     string
+	Selector *config.Selector
+}
+
+// String returns a human readable description of the decision.
+func (d Decision) String() string {
+	verb := "excluded"
+	if d.Included {
+		verb = "included"
+	}
+
+	switch {
+	case d.Path == "":
+		return verb + ", no filter applies"
+	case d.Selector == nil:
+		return fmt.Sprintf("%s, no selector in %s matched", verb, d.Path)
+	default:
+		return fmt.Sprintf("%s by %s %s", verb, d.Path, d.Selector)
+	}
+}
+
+// NewMatcher returns a Matcher for filters.
+func NewMatcher(filters []config.TargetFilter) *Matcher {
+	hits := make([]selectorHits, len(filters))
+	for i, filter := range filters {
+		if filter.Filter == nil {
+			continue
+		}
+
+		hits[i] = selectorHits{
+			include: make([]int, len(filter.Filter.Include)),
+			exclude: make([]int, len(filter.Filter.Exclude)),
+		}
+	}
+
+	return &Matcher{
+		filters: filters,
+		hits:    hits,
+	}
+}
+
+// Matches reports whether the document passes every filter and records selector hits.
+func (m *Matcher) Matches(d ParsedDocument) bool {
+	return m.evaluate(d, true).Included
+}
+
+// Explain returns the decision for the document without recording selector hits.
+//
+// The first exclude selector that matches decides an exclusion. Otherwise, the
+// first filter without a matching include selector decides it. An included
+// document is attributed to the first matching include selector.
+func (m *Matcher) Explain(d ParsedDocument) Decision {
+	return m.evaluate(d, false)
+}
+
+// Selectors returns all selectors in declaration order along with their hit counts.
+func (m *Matcher) Selectors() []SelectorHit {
+	var selectors []SelectorHit
+
+	for i, filter := range m.filters {
+		if filter.Filter == nil {
+			continue
+		}
+
+		for j, selector := range filter.Filter.Include {
+			selectors = append(selectors, SelectorHit{
+				Path:     includePath(filter, j),
+				Selector: selector,
+				Hits:     m.hits[i].include[j],
+			})
+		}
+
+		for j, selector := range filter.Filter.Exclude {
+			selectors = append(selectors, SelectorHit{
+				Path:     excludePath(filter, j),
+				Selector: selector,
+				Hits:     m.hits[i].exclude[j],
+			})
+		}
+	}
+
+	return selectors
+}
+
+// UnmatchedSelectors returns selectors that did not match any document.
+func (m *Matcher) UnmatchedSelectors() []SelectorHit {
+	var unmatched []SelectorHit
+
+	for _, selector := range m.Selectors() {
+		if selector.Hits == 0 {
+			unmatched = append(unmatched, selector)
+		}
+	}
+
+	return unmatched
+}
+
+func (m *Matcher) evaluate(d ParsedDocument, record bool) Decision {
+	var includedBy, excludedBy, notIncludedBy, notExcludedBy *Decision
+
+	for i, filter := range m.filters {
+		if filter.Filter == nil {
+			continue
+		}
+
+		included := len(filter.Filter.Include) == 0
+		for j, selector := range filter.Filter.Include {
+			if !d.matchesSelector(selector) {
+				continue
+			}
+
+			if record {
+				m.hits[i].include[j]++
+			}
+
+			if !included && includedBy == nil {
+				includedBy = &Decision{Included: true, Path: includePath(filter, j), Selector: &selector}
+			}
+
+			included = true
+		}
+
+		if !included && notIncludedBy == nil {
+			notIncludedBy = &Decision{Included: false, Path: filter.Path + ".include"}
+		}
+
+		if len(filter.Filter.Exclude) > 0 && notExcludedBy == nil {
+			notExcludedBy = &Decision{Included: true, Path: filter.Path + ".exclude"}
+		}
+
+		for j, selector := range filter.Filter.Exclude {
+			if !d.matchesSelector(selector) {
+				continue
+			}
+
+			if record {
+				m.hits[i].exclude[j]++
+			}
+
+			if excludedBy == nil {
+				excludedBy = &Decision{Included: false, Path: excludePath(filter, j), Selector: &selector}
+			}
+		}
+	}
+
+	switch {
+	case excludedBy != nil:
+		return *excludedBy
+	case notIncludedBy != nil:
+		return *notIncludedBy
+	case includedBy != nil:
+		return *includedBy
+	case notExcludedBy != nil:
+		return *notExcludedBy
+	default:
+		return Decision{Included: true}
+	}
+}
+
+func excludePath(filter config.TargetFilter, index int) string {
+	return fmt.Sprintf("%s.exclude[%d]", filter.Path, index)
+}
+
+func includePath(filter config.TargetFilter, index int) string {
+	return fmt.Sprintf("%s.include[%d]", filter.Path, index)
+}
@@ -0,0 +1,86 @@
+package manifest
+
+// clusterScopedKinds lists built-in cluster-scoped Kubernetes resources by group and kind.
+var clusterScopedKinds = map[groupKind]struct{}{
+	{"", "ComponentStatus"}:  {},
+	{"", "Namespace"}:        {},
+	{"", "Node"}:             {},
+	{"", "PersistentVolume"}: {},
+	{"admissionregistration.k8s.io", "MutatingAdmissionPolicy"}:          {},
+	{"admissionregistration.k8s.io", "MutatingAdmissionPolicyBinding"}:   {},
+	{"admissionregistration.k8s.io", "MutatingWebhookConfiguration"}:     {},
+	{"admissionregistration.k8s.io", "ValidatingAdmissionPolicy"}:        {},
+	{"admissionregistration.k8s.io", "ValidatingAdmissionPolicyBinding"}: {},
+	{"admissionregistration.k8s.io", "ValidatingWebhookConfiguration"}:   {},
+	{"apiextensions.k8s.io", "CustomResourceDefinition"}:                 {},
+	{"apiregistration.k8s.io", "APIService"}:                             {},
+	{"certificates.k8s.io", "CertificateSigningRequest"}:                 {},
+	{"certificates.k8s.io", "ClusterTrustBundle"}:                        {},
+	{"flowcontrol.apiserver.k8s.io", "FlowSchema"}:                       {},
+	{"flowcontrol.apiserver.k8s.io", "PriorityLevelConfiguration"}:       {},
+	{"networking.k8s.io", "IngressClass"}:                                {},
+	{"networking.k8s.io", "IPAddress"}:                                   {},
+	{"networking.k8s.io", "ServiceCIDR"}:                                 {},
+	{"node.k8s.io", "RuntimeClass"}:                                      {},
+	{"policy", "PodSecurityPolicy"}:                                      {},
+	{"rbac.authorization.k8s.io", "ClusterRole"}:                         {},
+	{"rbac.authorization.k8s.io", "ClusterRoleBinding"}:                  {},
+	{"resource.k8s.io", "DeviceClass"}:                                   {},
+	{"scheduling.k8s.io", "PriorityClass"}:                               {},
+	{"storage.k8s.io", "CSIDriver"}:                                      {},
+	{"storage.k8s.io", "CSINode"}:                                        {},
+	{"storage.k8s.io", "StorageClass"}:                                   {},
+	{"storage.k8s.io", "VolumeAttachment"}:                               {},
+	{"storage.k8s.io", "VolumeAttributesClass"}:                          {},
+}
+
+type groupKind struct {
+	group string
+	kind  string
+}
+
+// Scopes tells cluster-scoped resources apart from namespaced ones. It knows
+// built-in Kubernetes resources and custom resources whose
+// CustomResourceDefinition was rendered. Unknown resources are assumed to be
+// namespaced.
+type Scopes struct {
+	clusterScoped map[groupKind]struct{}
+}
+
+// NewScopes returns Scopes for built-in resources and CRDs found in documents.
+func NewScopes(documents []ParsedDocument) Scopes {
+	clusterScoped := make(map[groupKind]struct{})
+
+	for _, pd := range documents {
+		if pd.Metadata.Group != "apiextensions.k8s.io" || pd.Metadata.Kind != "CustomResourceDefinition" {
+			continue
+		}
+
+		spec, _ := getMapSliceValue(pd.Document, "spec")
+		if getNestedString(spec, "scope") != "Cluster" {
+			continue
+		}
+
+		names, _ := getNestedValue(spec, "names")
+
+		clusterScoped[groupKind{
+			group: getNestedString(spec, "group"),
+			kind:  getNestedString(names, "kind"),
+		}] = struct{}{}
+	}
+
+	return Scopes{clusterScoped: clusterScoped}
+}
+
+// IsClusterScoped reports whether the document is a cluster-scoped resource.
+func (s Scopes) IsClusterScoped(pd ParsedDocument) bool {
+	key := groupKind{group: pd.Metadata.Group, kind: pd.Metadata.Kind}
+
+	if _, ok := clusterScopedKinds[key]; ok {
+		return true
+	}
+
+	_, ok := s.clusterScoped[key]
+
+	return ok
+}
@@ -0,0 +1,152 @@
+package manifest
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+)
+
+// MetadataIssue describes a malformed or unexpected field used to identify,
+// filter or name a document.
+type MetadataIssue struct {
+	// Index is the position of the document in the rendered output.
+	Index    int
+	Document ParsedDocument
+	// Path is the offending field, in the syntax of transform paths.
+	Path   string
+	Value  any
+	Reason string
+}
+
+// String returns a description of the issue, e.g.
+// "document 3 (apps/v1 Deployment web): metadata.labels[version]: expected a string, got 1.5".
+func (i MetadataIssue) String() string {
+	return fmt.Sprintf("document %d (%s): %s: %s", i.Index, i.Document, i.Path, i.Reason)
+}
+
+// ValidateMetadata reports documents without apiVersion or kind, and
+// documents whose name, generateName, namespace, labels or annotations are not
+// strings. Such fields are otherwise treated as empty, which makes filters and
+// file names unpredictable.
+func ValidateMetadata(documents []ParsedDocument) []MetadataIssue {
+	var issues []MetadataIssue
+
+	for index, pd := range documents {
+		report := func(path string, value any, reason string) {
+			issues = append(issues, MetadataIssue{
+				Index:    index,
+				Document: pd,
+				Path:     path,
+				Value:    value,
+				Reason:   reason,
+			})
+		}
+
+		validateString(pd.Document, "apiVersion", "apiVersion", true, report)
+		validateString(pd.Document, "kind", "kind", true, report)
+
+		// nameless resources are handled by config.NamelessPolicy
+		metadata, ok := getMapSliceValue(pd.Document, "metadata")
+		if !ok || metadata == nil {
+			continue
+		}
+
+		if !isMapping(metadata) {
+			report("metadata", metadata, "expected a mapping, got "+describeValue(metadata))
+			continue
+		}
+
+		validateString(metadata, "name", "metadata.name", false, report)
+		validateString(metadata, "generateName", "metadata.generateName", false, report)
+		validateString(metadata, "namespace", "metadata.namespace", false, report)
+		validateStringMap(metadata, "labels", "metadata.labels", report)
+		validateStringMap(metadata, "annotations", "metadata.annotations", report)
+	}
+
+	return issues
+}
+
+type reportFunc func(path string, value any, reason string)
+
+func validateString(document any, key, path string, required bool, report reportFunc) {
+	value, ok := getNestedValue(document, key)
+	if !ok || value == nil {
+		if required {
+			report(path, value, "is missing")
+		}
+
+		return
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		report(path, value, "expected a string, got "+describeValue(value))
+		return
+	}
+
+	if str == "" && required {
+		report(path, value, "is empty")
+	}
+}
+
+func validateStringMap(document any, key, path string, report reportFunc) {
+	value, ok := getNestedValue(document, key)
+	if !ok || value == nil {
+		return
+	}
+
+	check := func(key, value any) {
+		name, ok := key.(string)
+		if !ok {
+			report(path, key, "expected string keys, got "+describeValue(key))
+			return
+		}
+
+		if _, ok := value.(string); !ok {
+			report(fmt.Sprintf("%s[%s]", path, name), value, "expected a string, got "+describeValue(value))
+		}
+	}
+
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		for _, item := range value {
+			check(item.Key, item.Value)
+		}
+
+	case map[string]any:
+		for _, name := range slices.Sorted(maps.Keys(value)) {
+			check(name, value[name])
+		}
+
+	default:
+		report(path, value, "expected a mapping, got "+describeValue(value))
+	}
+}
+
+func isMapping(value any) bool {
+	switch value.(type) {
+	case yaml.MapSlice, map[string]any:
+		return true
+	}
+
+	return false
+}
+
+// describeValue returns a short description of a decoded value for error messages.
+func describeValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case yaml.MapSlice, map[string]any:
+		return "a mapping"
+	case []any:
+		return "a list"
+	case string:
+		return fmt.Sprintf("%q", value)
+	}
+
+	return strings.TrimSpace(fmt.Sprint(value))
+}
@@ -0,0 +1,359 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/spf13/afero"
+
+	"github.com/artuross/kubesource/internal/manifest"
+
+	_ "embed"
+)
+
+// builtinSchemas holds schemas of common built-in resources. They do not
+// depend on the Kubernetes version and reject unknown fields of the objects
+// they describe; complete schemas can be provided with local directories.
+//
+//go:embed builtin.json
+var builtinSchemas []byte
+
+// Registry finds the schema of a resource. Schemas derived from
+// CustomResourceDefinitions take precedence over local schema directories,
+// which take precedence over the bundled schemas.
+type Registry struct {
+	afs               afero.Fs
+	kubernetesVersion string
+	directories       []string
+
+	crds    map[string]*Schema
+	files   map[string]*Schema
+	builtin map[string]any
+	meta    *Schema
+}
+
+// NewRegistry returns a registry that looks up schemas in directories, using
+// the layout of kubernetes-json-schema and the CRDs catalog:
+//
+//	<directory>/[v<kubernetesVersion>[-standalone[-strict]]/]<kind>[-<group>]-<version>.json
+//	<directory>/<group>/<kind>_<version>.json
+func NewRegistry(afs afero.Fs, kubernetesVersion string, directories []string) (*Registry, error) {
+	var builtin map[string]any
+	if err := json.Unmarshal(builtinSchemas, &builtin); err != nil {
+		return nil, fmt.Errorf("parsing bundled schemas: %w", err)
+	}
+
+	return &Registry{
+		afs:               afs,
+		kubernetesVersion: strings.TrimPrefix(kubernetesVersion, "v"),
+		directories:       directories,
+		crds:              make(map[string]*Schema),
+		files:             make(map[string]*Schema),
+		builtin:           builtin,
+		meta:              New(map[string]any{"$ref": "#/definitions/ObjectMeta"}, builtin),
+	}, nil
+}
+
+// AddCRDs registers the schemas of every version of the CustomResourceDefinitions in documents.
+func (r *Registry) AddCRDs(documents []manifest.ParsedDocument) {
+	for _, pd := range documents {
+		if pd.Metadata.Kind != "CustomResourceDefinition" || pd.Metadata.Group != "apiextensions.k8s.io" {
+			continue
+		}
+
+		crd, _ := plain(pd.Document).(map[string]any)
+		spec, _ := crd["spec"].(map[string]any)
+		group, _ := spec["group"].(string)
+		names, _ := spec["names"].(map[string]any)
+		kind, _ := names["kind"].(string)
+
+		// apiextensions.k8s.io/v1beta1 allowed a schema shared by all versions
+		shared, _ := spec["validation"].(map[string]any)
+
+		versions, _ := spec["versions"].([]any)
+		for _, version := range versions {
+			version, _ := version.(map[string]any)
+			name, _ := version["name"].(string)
+
+			validation, _ := version["schema"].(map[string]any)
+			if validation == nil {
+				validation = shared
+			}
+
+			openAPISchema, _ := validation["openAPIV3Schema"].(map[string]any)
+			if openAPISchema == nil || name == "" || kind == "" {
+				continue
+			}
+
+			r.crds[schemaKey(group, name, kind)] = crdSchema(openAPISchema)
+		}
+	}
+}
+
+// LoadCRDs registers the CustomResourceDefinitions in the YAML files at paths,
+// which may be files or directories.
+func (r *Registry) LoadCRDs(paths []string) error {
+	for _, root := range paths {
+		err := afero.Walk(r.afs, root, func(filePath string, info fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() || (path.Ext(filePath) != ".yaml" && path.Ext(filePath) != ".yml") {
+				return nil
+			}
+
+			content, err := afero.ReadFile(r.afs, filePath)
+			if err != nil {
+				return err
+			}
+
+			documents, err := manifest.ParseDocuments(content)
+			if err != nil {
+				return fmt.Errorf("parsing %s: %w", filePath, err)
+			}
+
+			r.AddCRDs(documents)
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("loading CRDs from %s: %w", root, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate returns the violations of the schema of pd, and false if no schema was found.
+func (r *Registry) Validate(pd manifest.ParsedDocument) ([]Error, bool, error) {
+	schema, err := r.lookup(pd.Metadata.Group, pd.Metadata.Version, pd.Metadata.Kind)
+	if err != nil || schema == nil {
+		return nil, false, err
+	}
+
+	document := plain(pd.Document)
+
+	// sops metadata of encrypted resources is removed when they are decrypted
+	// before being applied
+	if object, ok := document.(map[string]any); ok {
+		if _, encrypted := object["sops"].(map[string]any); encrypted {
+			delete(object, "sops")
+		}
+	}
+
+	errs := schema.Validate(document)
+
+	// the API server validates metadata of custom resources itself
+	if _, ok := r.crds[schemaKey(pd.Metadata.Group, pd.Metadata.Version, pd.Metadata.Kind)]; ok {
+		if metadata, ok := document.(map[string]any)["metadata"]; ok {
+			for _, err := range r.meta.Validate(metadata) {
+				err.Path = strings.TrimSuffix("metadata."+err.Path, ".")
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errs, true, nil
+}
+
+func (r *Registry) lookup(group, version, kind string) (*Schema, error) {
+	key := schemaKey(group, version, kind)
+
+	if schema, ok := r.crds[key]; ok {
+		return schema, nil
+	}
+
+	for _, directory := range r.directories {
+		for _, candidate := range r.candidates(directory, group, version, kind) {
+			schema, err := r.loadFile(candidate)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+
+			if err != nil {
+				return nil, err
+			}
+
+			return schema, nil
+		}
+	}
+
+	kinds, _ := r.builtin["kinds"].(map[string]any)
+	if node, ok := kinds[key].(map[string]any); ok {
+		return New(node, r.builtin), nil
+	}
+
+	return nil, nil
+}
+
+// candidates returns the paths a schema may be stored at in directory.
+func (r *Registry) candidates(directory, group, version, kind string) []string {
+	kind = strings.ToLower(kind)
+
+	name := kind + "-" + version + ".json"
+	if group != "" {
+		name = kind + "-" + strings.Split(group, ".")[0] + "-" + version + ".json"
+	}
+
+	directories := append(r.versionDirectories(directory), directory)
+
+	candidates := make([]string, 0, len(directories)+1)
+	for _, dir := range directories {
+		candidates = append(candidates, path.Join(dir, name))
+	}
+
+	if group != "" {
+		candidates = append(candidates, path.Join(directory, group, kind+"_"+version+".json"))
+	}
+
+	return candidates
+}
+
+// versionDirectories returns the subdirectories of directory holding schemas
+// for the Kubernetes version, in the layout of kubernetes-json-schema.
+func (r *Registry) versionDirectories(directory string) []string {
+	if r.kubernetesVersion == "" {
+		return nil
+	}
+
+	directories := make([]string, 0, 3)
+	for _, suffix := range []string{"-standalone-strict", "-standalone", ""} {
+		directories = append(directories, path.Join(directory, "v"+r.kubernetesVersion+suffix))
+	}
+
+	return directories
+}
+
+// CheckVersion returns an error if the Kubernetes version is set but no
+// directory holds schemas for it. Resources are then validated against the
+// bundled schemas, which do not depend on the version.
+func (r *Registry) CheckVersion() error {
+	if r.kubernetesVersion == "" {
+		return nil
+	}
+
+	var checked []string
+	for _, directory := range r.directories {
+		for _, versionDirectory := range r.versionDirectories(directory) {
+			exists, err := afero.DirExists(r.afs, versionDirectory)
+			if err != nil {
+				return err
+			}
+
+			if exists {
+				return nil
+			}
+
+			checked = append(checked, versionDirectory)
+		}
+	}
+
+	if len(checked) == 0 {
+		return fmt.Errorf("no schema directories for Kubernetes %s", r.kubernetesVersion)
+	}
+
+	return fmt.Errorf("no schemas for Kubernetes %s, none of %s exists", r.kubernetesVersion, strings.Join(checked, ", "))
+}
+
+// loadFile parses the JSON schema at filePath. References to other files are
+// resolved relative to its directory.
+func (r *Registry) loadFile(filePath string) (*Schema, error) {
+	if schema, ok := r.files[filePath]; ok {
+		return schema, nil
+	}
+
+	content, err := afero.ReadFile(r.afs, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var root map[string]any
+	if err := json.Unmarshal(content, &root); err != nil {
+		return nil, fmt.Errorf("parsing schema %s: %w", filePath, err)
+	}
+
+	schema := New(root, root)
+	schema.load = func(file string) (*Schema, error) {
+		return r.loadFile(path.Join(path.Dir(filePath), file))
+	}
+
+	r.files[filePath] = schema
+
+	return schema, nil
+}
+
+// crdSchema returns the schema of a custom resource. apiVersion, kind and
+// metadata are implicit fields of every resource.
+func crdSchema(openAPISchema map[string]any) *Schema {
+	root := make(map[string]any, len(openAPISchema))
+	for key, value := range openAPISchema {
+		root[key] = value
+	}
+
+	properties := make(map[string]any)
+	if declared, ok := openAPISchema["properties"].(map[string]any); ok {
+		for key, value := range declared {
+			properties[key] = value
+		}
+	}
+
+	for _, name := range []string{"apiVersion", "kind"} {
+		if _, ok := properties[name]; !ok {
+			properties[name] = map[string]any{"type": "string"}
+		}
+	}
+
+	properties["metadata"] = map[string]any{"type": "object", "x-kubernetes-preserve-unknown-fields": true}
+	root["properties"] = properties
+
+	schema := New(root, root)
+	schema.rejectUnknown = true
+
+	return schema
+}
+
+func schemaKey(group, version, kind string) string {
+	if group == "" {
+		return version + "/" + kind
+	}
+
+	return group + "/" + version + "/" + kind
+}
+
+// plain converts a decoded document to map[string]any and []any, the
+// representation of JSON documents.
+func plain(value any) any {
+	switch value := value.(type) {
+	case yaml.MapSlice:
+		object := make(map[string]any, len(value))
+		for _, item := range value {
+			object[fmt.Sprint(item.Key)] = plain(item.Value)
+		}
+
+		return object
+
+	case map[string]any:
+		object := make(map[string]any, len(value))
+		for key, item := range value {
+			object[key] = plain(item)
+		}
+
+		return object
+
+	case []any:
+		list := make([]any, len(value))
+		for i, item := range value {
+			list[i] = plain(item)
+		}
+
+		return list
+	}
+
+	return value
+}
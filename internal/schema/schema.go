@@ -0,0 +1,431 @@
+// Package schema validates resources against a subset of JSON schema, as used
+// by the OpenAPI schemas of Kubernetes and CustomResourceDefinitions.
+//
+// Supported keywords are type, format int-or-string, nullable, enum, $ref,
+// properties, required, additionalProperties, items, minItems, maxItems,
+// minLength, maxLength, pattern, minimum, maximum, allOf, anyOf, oneOf,
+// x-kubernetes-int-or-string and x-kubernetes-preserve-unknown-fields. Other
+// keywords are ignored.
+package schema
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Error is a violation of a schema.
+type Error struct {
+	// Path is the offending field, e.g. "spec.template.spec.containers[0].image".
+	Path    string
+	Message string
+}
+
+// String returns the path and the message of the error.
+func (e Error) String() string {
+	if e.Path == "" {
+		return e.Message
+	}
+
+	return e.Path + ": " + e.Message
+}
+
+// Schema is a JSON schema together with the document its references resolve against.
+type Schema struct {
+	node map[string]any
+	root map[string]any
+	// load returns the document of a reference to another file, or nil if
+	// such references are not supported.
+	load func(file string) (*Schema, error)
+	// rejectUnknown reports fields not declared in properties, as the API
+	// server does for structural schemas of CustomResourceDefinitions.
+	rejectUnknown bool
+}
+
+// New returns a schema whose references resolve against root.
+func New(node, root map[string]any) *Schema {
+	return &Schema{node: node, root: root}
+}
+
+// Validate returns the violations of the schema by value.
+func (s *Schema) Validate(value any) []Error {
+	var errs []Error
+	s.validate(s.node, value, "", &errs)
+
+	return errs
+}
+
+func (s *Schema) validate(node map[string]any, value any, path string, errs *[]Error) {
+	if ref, ok := node["$ref"].(string); ok {
+		schema, target, err := s.resolve(ref)
+		if err != nil {
+			*errs = append(*errs, Error{Path: path, Message: err.Error()})
+			return
+		}
+
+		schema.validate(target, value, path, errs)
+
+		return
+	}
+
+	report := func(format string, args ...any) {
+		*errs = append(*errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if value == nil {
+		types := schemaTypes(node)
+		if node["nullable"] == true || len(types) == 0 || slices.Contains(types, "null") {
+			return
+		}
+
+		report("expected %s, got null", strings.Join(types, " or "))
+
+		return
+	}
+
+	if node["x-kubernetes-int-or-string"] == true || node["format"] == "int-or-string" {
+		if _, isString := value.(string); !isString && !isInteger(value) {
+			report("expected integer or string, got %s", typeName(value))
+		}
+
+		return
+	}
+
+	if types := schemaTypes(node); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return hasType(value, t) }) {
+		report("expected %s, got %s", strings.Join(types, " or "), typeName(value))
+		return
+	}
+
+	if enum, ok := node["enum"].([]any); ok && !slices.ContainsFunc(enum, func(item any) bool { return equal(item, value) }) {
+		report("value %s is not one of %s", describe(value), describeList(enum))
+	}
+
+	switch value := value.(type) {
+	case string:
+		s.validateString(node, value, report)
+
+	case []any:
+		s.validateArray(node, value, path, errs, report)
+
+	case map[string]any:
+		s.validateObject(node, value, path, errs)
+
+	default:
+		if number, ok := toFloat(value); ok {
+			if minimum, ok := toFloat(node["minimum"]); ok && number < minimum {
+				report("value %s is less than the minimum %s", describe(value), describe(node["minimum"]))
+			}
+
+			if maximum, ok := toFloat(node["maximum"]); ok && number > maximum {
+				report("value %s is greater than the maximum %s", describe(value), describe(node["maximum"]))
+			}
+		}
+	}
+
+	s.validateCombinations(node, value, path, errs, report)
+}
+
+func (s *Schema) validateString(node map[string]any, value string, report func(string, ...any)) {
+	length := len([]rune(value))
+
+	if minLength, ok := toFloat(node["minLength"]); ok && float64(length) < minLength {
+		report("length %d is less than the minimum %s", length, describe(node["minLength"]))
+	}
+
+	if maxLength, ok := toFloat(node["maxLength"]); ok && float64(length) > maxLength {
+		report("length %d is greater than the maximum %s", length, describe(node["maxLength"]))
+	}
+
+	if pattern, ok := node["pattern"].(string); ok {
+		// patterns that are not valid RE2 expressions are ignored
+		if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(value) {
+			report("value %s does not match the pattern %s", describe(value), pattern)
+		}
+	}
+}
+
+func (s *Schema) validateArray(node map[string]any, value []any, path string, errs *[]Error, report func(string, ...any)) {
+	if minItems, ok := toFloat(node["minItems"]); ok && float64(len(value)) < minItems {
+		report("expected at least %s items, got %d", describe(node["minItems"]), len(value))
+	}
+
+	if maxItems, ok := toFloat(node["maxItems"]); ok && float64(len(value)) > maxItems {
+		report("expected at most %s items, got %d", describe(node["maxItems"]), len(value))
+	}
+
+	items, ok := node["items"].(map[string]any)
+	if !ok {
+		return
+	}
+
+	for i, item := range value {
+		s.validate(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+	}
+}
+
+func (s *Schema) validateObject(node map[string]any, value map[string]any, path string, errs *[]Error) {
+	properties, _ := node["properties"].(map[string]any)
+
+	if required, ok := node["required"].([]any); ok {
+		for _, name := range required {
+			name, ok := name.(string)
+			if !ok {
+				continue
+			}
+
+			if _, ok := value[name]; !ok {
+				*errs = append(*errs, Error{Path: joinPath(path, name), Message: "is required"})
+			}
+		}
+	}
+
+	additional, hasAdditional := node["additionalProperties"]
+	preserveUnknown := node["x-kubernetes-preserve-unknown-fields"] == true
+
+	for _, name := range slices.Sorted(maps.Keys(value)) {
+		fieldPath := joinPath(path, name)
+
+		if property, ok := properties[name].(map[string]any); ok {
+			s.validate(property, value[name], fieldPath, errs)
+			continue
+		}
+
+		switch additional := additional.(type) {
+		case map[string]any:
+			s.validate(additional, value[name], fieldPath, errs)
+			continue
+
+		case bool:
+			if !additional {
+				*errs = append(*errs, Error{Path: fieldPath, Message: "unknown field"})
+			}
+
+			continue
+		}
+
+		if s.rejectUnknown && !hasAdditional && !preserveUnknown && properties != nil {
+			*errs = append(*errs, Error{Path: fieldPath, Message: "unknown field"})
+		}
+	}
+}
+
+func (s *Schema) validateCombinations(node map[string]any, value any, path string, errs *[]Error, report func(string, ...any)) {
+	if allOf, ok := node["allOf"].([]any); ok {
+		for _, item := range allOf {
+			if item, ok := item.(map[string]any); ok {
+				s.validate(item, value, path, errs)
+			}
+		}
+	}
+
+	if anyOf, ok := node["anyOf"].([]any); ok && s.countValid(anyOf, value, path) == 0 {
+		report("does not match any of the allowed schemas")
+	}
+
+	if oneOf, ok := node["oneOf"].([]any); ok {
+		if count := s.countValid(oneOf, value, path); count != 1 {
+			report("matches %d of the schemas, expected exactly one", count)
+		}
+	}
+}
+
+// countValid returns the number of schemas in list that value is valid against.
+func (s *Schema) countValid(list []any, value any, path string) int {
+	count := 0
+
+	for _, item := range list {
+		item, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		var errs []Error
+		s.validate(item, value, path, &errs)
+
+		if len(errs) == 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+// resolve returns the schema node referenced by ref, either "#/pointer" or
+// "file#/pointer", and the schema it belongs to.
+func (s *Schema) resolve(ref string) (*Schema, map[string]any, error) {
+	file, pointer, _ := strings.Cut(ref, "#")
+
+	schema := s
+	if file != "" {
+		if s.load == nil {
+			return nil, nil, fmt.Errorf("unsupported reference %s", ref)
+		}
+
+		loaded, err := s.load(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolving reference %s: %w", ref, err)
+		}
+
+		schema = loaded
+	}
+
+	var node any = schema.root
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, nil, fmt.Errorf("unresolvable reference %s", ref)
+		}
+
+		node = object[token]
+	}
+
+	target, ok := node.(map[string]any)
+	if !ok {
+		return nil, nil, fmt.Errorf("unresolvable reference %s", ref)
+	}
+
+	return schema, target, nil
+}
+
+func schemaTypes(node map[string]any) []string {
+	switch types := node["type"].(type) {
+	case string:
+		return []string{types}
+
+	case []any:
+		var names []string
+		for _, name := range types {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+
+		return names
+	}
+
+	return nil
+}
+
+func hasType(value any, name string) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "integer":
+		return isInteger(value)
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "null":
+		return value == nil
+	}
+
+	// unknown types are not checked
+	return true
+}
+
+func typeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+
+	if isInteger(value) {
+		return "integer"
+	}
+
+	if _, ok := toFloat(value); ok {
+		return "number"
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+func isInteger(value any) bool {
+	number, ok := toFloat(value)
+
+	return ok && number == math.Trunc(number) && !math.IsInf(number, 0)
+}
+
+func toFloat(value any) (float64, bool) {
+	switch value := value.(type) {
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case uint64:
+		return float64(value), true
+	case float64:
+		return value, true
+	}
+
+	return 0, false
+}
+
+// equal compares decoded values, treating numbers of different types as equal.
+func equal(a, b any) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func describe(value any) string {
+	if str, ok := value.(string); ok {
+		return fmt.Sprintf("%q", str)
+	}
+
+	return fmt.Sprint(value)
+}
+
+func describeList(values []any) string {
+	descriptions := make([]string, 0, len(values))
+	for _, value := range values {
+		descriptions = append(descriptions, describe(value))
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+// joinPath appends key to path, wrapping keys containing dots or brackets in
+// square brackets like transform paths.
+func joinPath(path, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		return path + "[" + key + "]"
+	}
+
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
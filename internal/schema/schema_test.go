@@ -0,0 +1,216 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/internal/schema"
+)
+
+func TestRegistry(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(afs, "schemas/v1.30.0-standalone/gadget-example-v1.json", []byte(`{
+  "type": "object",
+  "properties": {
+    "spec": {"$ref": "#/definitions/spec"}
+  },
+  "definitions": {
+    "spec": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {"enabled": {"type": "boolean"}}
+    }
+  }
+}`), 0o644))
+
+	registry, err := schema.NewRegistry(afs, "v1.30.0", []string{"schemas"})
+	require.NoError(t, err)
+
+	documents, err := manifest.ParseDocuments([]byte(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+    - name: v1
+      served: true
+      storage: true
+      schema:
+        openAPIV3Schema:
+          type: object
+          properties:
+            spec:
+              type: object
+              required: [size]
+              properties:
+                size:
+                  type: integer
+                  minimum: 1
+                port:
+                  x-kubernetes-int-or-string: true
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+  labels:
+    version: 1.5
+spec:
+  size: 0
+  port: http
+  color: red
+---
+apiVersion: example.com/v1
+kind: Gadget
+metadata:
+  name: gadget
+spec:
+  enabled: "yes"
+  mode: fast
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: "3"
+  replica: 3
+  selector:
+    matchLabels:
+      app: web
+  template:
+    spec:
+      containers:
+        - image: nginx
+          resources:
+            limits:
+              cpu: 0.5
+              memory: 128Mi
+---
+apiVersion: example.com/v1
+kind: Unknown
+metadata:
+  name: unknown
+`))
+	require.NoError(t, err)
+
+	registry.AddCRDs(documents)
+
+	tests := []struct {
+		document string
+		expected []string
+	}{
+		{
+			document: "apiextensions.k8s.io/v1 CustomResourceDefinition widgets.example.com",
+			expected: nil,
+		},
+		{
+			document: "example.com/v1 Widget widget",
+			expected: []string{
+				"spec.color: unknown field",
+				"spec.size: value 0 is less than the minimum 1",
+				"metadata.labels.version: expected string, got number",
+			},
+		},
+		{
+			document: "example.com/v1 Gadget gadget",
+			expected: []string{
+				"spec.enabled: expected boolean, got string",
+				"spec.mode: unknown field",
+			},
+		},
+		{
+			document: "apps/v1 Deployment web",
+			expected: []string{
+				"spec.replica: unknown field",
+				"spec.replicas: expected integer, got string",
+				"spec.template.spec.containers[0].name: is required",
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.document, func(t *testing.T) {
+			require.Equal(t, tt.document, documents[i].String())
+
+			errs, found, err := registry.Validate(documents[i])
+			require.NoError(t, err)
+			require.True(t, found)
+
+			var messages []string
+			for _, err := range errs {
+				messages = append(messages, err.String())
+			}
+
+			assert.Equal(t, tt.expected, messages)
+		})
+	}
+
+	_, found, err := registry.Validate(documents[4])
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestRegistryCheckVersion(t *testing.T) {
+	afs := afero.NewMemMapFs()
+	require.NoError(t, afs.MkdirAll("schemas/v1.30.0-standalone-strict", 0o755))
+
+	tests := []struct {
+		version     string
+		directories []string
+		expected    string
+	}{
+		{version: "", directories: nil},
+		{version: "1.30.0", directories: []string{"schemas"}},
+		{
+			version:     "v1.31.0",
+			directories: []string{"schemas"},
+			expected:    "no schemas for Kubernetes 1.31.0, none of schemas/v1.31.0-standalone-strict, schemas/v1.31.0-standalone, schemas/v1.31.0 exists",
+		},
+		{
+			version:     "v1.31.0",
+			directories: nil,
+			expected:    "no schema directories for Kubernetes 1.31.0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			registry, err := schema.NewRegistry(afs, tt.version, tt.directories)
+			require.NoError(t, err)
+
+			err = registry.CheckVersion()
+			if tt.expected == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			require.EqualError(t, err, tt.expected)
+
+			// the bundled schemas are still used
+			documents, err := manifest.ParseDocuments([]byte(`apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  port: 80
+`))
+			require.NoError(t, err)
+
+			errs, found, err := registry.Validate(documents[0])
+			require.NoError(t, err)
+			require.True(t, found)
+			require.Len(t, errs, 1)
+			assert.Equal(t, "spec.port: unknown field", errs[0].String())
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package transform
+
+import (
+	"fmt"
+	"io"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+// crdSchemaPaths locate the schemas of apiextensions.k8s.io/v1 and v1beta1 CustomResourceDefinitions.
+var crdSchemaPaths = []Path{
+	mustParsePath("spec.versions[*].schema.openAPIV3Schema"),
+	mustParsePath("spec.validation.openAPIV3Schema"),
+}
+
+// lastAppliedLimit is the maximum size of all annotations of a resource, which
+// includes the last-applied-configuration annotation of client-side apply.
+const lastAppliedLimit = 262144
+
+// schemaMapKeywords hold mappings of property names to schemas.
+var schemaMapKeywords = []string{"properties", "patternProperties", "definitions"}
+
+// schemaKeywords hold a schema, a list of schemas or, for additionalProperties, a boolean.
+var schemaKeywords = []string{"items", "additionalProperties", "additionalItems", "not", "allOf", "anyOf", "oneOf"}
+
+// stripCRDDescriptions returns a step that removes description fields from the
+// schemas of CustomResourceDefinitions according to policy. The size of every
+// changed CustomResourceDefinition before and after is reported to out.
+func stripCRDDescriptions(policy config.CRDDescriptionPolicy, out io.Writer) step {
+	keepTopLevel := policy == config.CRDDescriptionsKeepTopLevel
+
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		if pd.Metadata.Kind != "CustomResourceDefinition" || pd.Metadata.Group != "apiextensions.k8s.io" {
+			return pd.Document, nil
+		}
+
+		before, err := jsonSize(pd.Document)
+		if err != nil {
+			return nil, err
+		}
+
+		var document any = pd.Document
+		for _, path := range crdSchemaPaths {
+			document = path.Visit(document, func(schema any) any {
+				return stripSchemaDescriptions(schema, 0, keepTopLevel)
+			})
+		}
+
+		stripped, _ := document.(yaml.MapSlice)
+
+		after, err := jsonSize(stripped)
+		if err != nil {
+			return nil, err
+		}
+
+		if out != nil && after != before {
+			fmt.Fprintf(out, "  Stripped descriptions of %s: %s -> %s\n", pd, formatSize(before), formatSize(after))
+		}
+
+		if out != nil && after > lastAppliedLimit {
+			fmt.Fprintf(out, "  Warning: %s is still larger than %s and cannot be applied client-side\n", pd, formatSize(lastAppliedLimit))
+		}
+
+		return stripped, nil
+	}
+}
+
+// stripSchemaDescriptions removes description from schema and its subschemas.
+// With keepTopLevel, descriptions of the root schema (depth 0) and its
+// properties (depth 1) are kept. Properties named "description" are schemas,
+// not descriptions, so only known schema keywords are descended into.
+func stripSchemaDescriptions(schema any, depth int, keepTopLevel bool) any {
+	if !isMap(schema) {
+		if list, ok := schema.([]any); ok {
+			for i, item := range list {
+				list[i] = stripSchemaDescriptions(item, depth, keepTopLevel)
+			}
+		}
+
+		return schema
+	}
+
+	if !keepTopLevel || depth > 1 {
+		schema = removeKey(schema, "description")
+	}
+
+	for _, keyword := range schemaMapKeywords {
+		properties, ok := getKey(schema, keyword)
+		if !ok || !isMap(properties) {
+			continue
+		}
+
+		for _, name := range mapKeys(properties) {
+			property, _ := getKey(properties, name)
+			properties = setKey(properties, name, stripSchemaDescriptions(property, depth+1, keepTopLevel))
+		}
+
+		schema = setKey(schema, keyword, properties)
+	}
+
+	for _, keyword := range schemaKeywords {
+		if subschema, ok := getKey(schema, keyword); ok {
+			schema = setKey(schema, keyword, stripSchemaDescriptions(subschema, depth+1, keepTopLevel))
+		}
+	}
+
+	return schema
+}
+
+// jsonSize returns the size of document encoded as compact JSON, which is how
+// kubectl stores it in the last-applied-configuration annotation.
+func jsonSize(document yaml.MapSlice) (int, error) {
+	content, err := yaml.MarshalWithOptions(document, yaml.JSON())
+	if err != nil {
+		return 0, fmt.Errorf("measuring document size: %w", err)
+	}
+
+	return len(content), nil
+}
+
+func formatSize(size int) string {
+	if size < 1024 {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	return fmt.Sprintf("%.1f KiB", float64(size)/1024)
+}
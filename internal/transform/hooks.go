@@ -0,0 +1,171 @@
+package transform
+
+import (
+	"slices"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+const (
+	helmHookAnnotation             = "helm.sh/hook"
+	helmHookWeightAnnotation       = "helm.sh/hook-weight"
+	helmHookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+
+	argoCDHookAnnotation             = "argocd.argoproj.io/hook"
+	argoCDHookDeletePolicyAnnotation = "argocd.argoproj.io/hook-delete-policy"
+	argoCDSyncWaveAnnotation         = "argocd.argoproj.io/sync-wave"
+
+	fluxForceAnnotation = "kustomize.toolkit.fluxcd.io/force"
+)
+
+// argoCDHooks maps Helm hooks to Argo CD hooks. Helm hooks without an
+// equivalent, such as tests or rollback hooks, are not listed.
+var argoCDHooks = map[string]string{
+	"pre-install":  "PreSync",
+	"pre-upgrade":  "PreSync",
+	"post-install": "PostSync",
+	"post-upgrade": "PostSync",
+	"post-delete":  "PostDelete",
+}
+
+// argoCDHookDeletePolicies maps Helm hook delete policies to Argo CD ones.
+var argoCDHookDeletePolicies = map[string]string{
+	"before-hook-creation": "BeforeHookCreation",
+	"hook-succeeded":       "HookSucceeded",
+	"hook-failed":          "HookFailed",
+}
+
+// fluxHooks are Helm hooks kept when converting to Flux. Flux applies all
+// resources at once, so only hooks that run on install or upgrade make sense.
+var fluxHooks = []string{"pre-install", "pre-upgrade", "post-install", "post-upgrade"}
+
+// helmHooks returns the Helm hooks of a document, e.g. ["pre-install", "pre-upgrade"].
+func helmHooks(document yaml.MapSlice) []string {
+	annotations, _ := getKey(document, "metadata")
+	annotations, _ = getKey(annotations, "annotations")
+
+	value := getString(annotations, helmHookAnnotation)
+	if value == "" {
+		return nil
+	}
+
+	var hooks []string
+	for hook := range strings.SplitSeq(value, ",") {
+		if hook = strings.TrimSpace(hook); hook != "" {
+			hooks = append(hooks, hook)
+		}
+	}
+
+	return hooks
+}
+
+func isTestHook(hook string) bool {
+	return hook == "test" || hook == "test-success" || hook == "test-failure"
+}
+
+// handleHooks returns a step that applies the hooks policy to Helm hook resources.
+func handleHooks(policy config.HookPolicy) step {
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		hooks := helmHooks(pd.Document)
+		if len(hooks) == 0 {
+			return pd.Document, nil
+		}
+
+		switch policy {
+		case config.HookPolicyDrop:
+			return nil, nil
+
+		case config.HookPolicyDropTests:
+			if slices.ContainsFunc(hooks, isTestHook) {
+				return nil, nil
+			}
+
+		case config.HookPolicyArgoCD:
+			return convertToArgoCDHook(pd.Document, hooks), nil
+
+		case config.HookPolicyFlux:
+			return convertToFluxResource(pd, hooks), nil
+		}
+
+		return pd.Document, nil
+	}
+}
+
+// convertToArgoCDHook replaces Helm hook annotations with Argo CD ones. Hooks
+// without an Argo CD equivalent are dropped.
+func convertToArgoCDHook(document yaml.MapSlice, hooks []string) yaml.MapSlice {
+	var argoHooks []string
+	for _, hook := range hooks {
+		if argoHook, ok := argoCDHooks[hook]; ok && !slices.Contains(argoHooks, argoHook) {
+			argoHooks = append(argoHooks, argoHook)
+		}
+	}
+
+	if len(argoHooks) == 0 {
+		return nil
+	}
+
+	var deletePolicies []string
+	weight := ""
+
+	mustParsePath("metadata.annotations").Visit(document, func(annotations any) any {
+		for policy := range strings.SplitSeq(getString(annotations, helmHookDeletePolicyAnnotation), ",") {
+			if argoPolicy, ok := argoCDHookDeletePolicies[strings.TrimSpace(policy)]; ok {
+				deletePolicies = append(deletePolicies, argoPolicy)
+			}
+		}
+
+		weight = getString(annotations, helmHookWeightAnnotation)
+
+		return removeHelmHookAnnotations(annotations)
+	})
+
+	annotations := map[string]string{
+		argoCDHookAnnotation: strings.Join(argoHooks, ","),
+	}
+
+	if len(deletePolicies) > 0 {
+		annotations[argoCDHookDeletePolicyAnnotation] = strings.Join(deletePolicies, ",")
+	}
+
+	if weight != "" {
+		annotations[argoCDSyncWaveAnnotation] = weight
+	}
+
+	document, _ = addMetadata("annotations", config.CommonMetadata{Pairs: annotations})(manifest.ParsedDocument{Document: document})
+
+	return document
+}
+
+// convertToFluxResource turns a Helm hook into a regular resource. Flux has no
+// hooks, so only install and upgrade hooks are kept. Jobs and Pods are
+// immutable, so they are marked to be recreated by Flux when they change.
+func convertToFluxResource(pd manifest.ParsedDocument, hooks []string) yaml.MapSlice {
+	if !slices.ContainsFunc(hooks, func(hook string) bool { return slices.Contains(fluxHooks, hook) }) {
+		return nil
+	}
+
+	document := pd.Document
+
+	mustParsePath("metadata.annotations").Visit(document, removeHelmHookAnnotations)
+
+	if (pd.Metadata.Group == "batch" && pd.Metadata.Kind == "Job") || (pd.Metadata.Group == "" && pd.Metadata.Kind == "Pod") {
+		document, _ = addMetadata("annotations", config.CommonMetadata{
+			Pairs: map[string]string{fluxForceAnnotation: "Enabled"},
+		})(manifest.ParsedDocument{Document: document})
+	}
+
+	return document
+}
+
+func removeHelmHookAnnotations(annotations any) any {
+	for _, key := range []string{helmHookAnnotation, helmHookWeightAnnotation, helmHookDeletePolicyAnnotation} {
+		annotations = removeKey(annotations, key)
+	}
+
+	return annotations
+}
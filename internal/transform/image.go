@@ -0,0 +1,145 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/images"
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+// podSpecPaths lists paths to pod specs, keyed by the group and kind of resources containing them.
+var podSpecPaths = map[string]Path{
+	"/Pod":                   mustParsePath("spec"),
+	"/ReplicationController": mustParsePath("spec.template.spec"),
+	"apps/DaemonSet":         mustParsePath("spec.template.spec"),
+	"apps/Deployment":        mustParsePath("spec.template.spec"),
+	"apps/ReplicaSet":        mustParsePath("spec.template.spec"),
+	"apps/StatefulSet":       mustParsePath("spec.template.spec"),
+	"batch/CronJob":          mustParsePath("spec.jobTemplate.spec.template.spec"),
+	"batch/Job":              mustParsePath("spec.template.spec"),
+}
+
+// containerListPaths lists paths to images relative to a pod spec.
+var containerListPaths = []Path{
+	mustParsePath("containers[*].image"),
+	mustParsePath("initContainers[*].image"),
+	mustParsePath("ephemeralContainers[*].image"),
+}
+
+// imagePaths returns paths to image fields of a document.
+func imagePaths(pd manifest.ParsedDocument, custom []config.ImagePath) []Path {
+	var paths []Path
+
+	if podSpec, ok := podSpecPaths[pd.Metadata.Group+"/"+pd.Metadata.Kind]; ok {
+		for _, containers := range containerListPaths {
+			paths = append(paths, append(append(Path{}, podSpec...), containers...))
+		}
+	}
+
+	for _, imagePath := range custom {
+		if !pd.MatchesSelector(imagePath.Target) {
+			continue
+		}
+
+		// paths are validated by buildSteps
+		path, _ := ParsePath(imagePath.Path)
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// visitImages calls fn for every image of a document and replaces it with the returned value.
+func visitImages(pd manifest.ParsedDocument, custom []config.ImagePath, fn func(image string) (string, error)) (yaml.MapSlice, error) {
+	var err error
+
+	for _, path := range imagePaths(pd, custom) {
+		path.Visit(pd.Document, func(value any) any {
+			image, ok := value.(string)
+			if !ok || err != nil {
+				return value
+			}
+
+			updated, fnErr := fn(image)
+			if fnErr != nil {
+				err = fnErr
+				return value
+			}
+
+			return updated
+		})
+	}
+
+	return pd.Document, err
+}
+
+// rewriteImages returns a step that replaces registry prefixes of images
+// according to rewrites. The longest matching prefix wins.
+func rewriteImages(rewrites []config.ImageRewrite, custom []config.ImagePath) step {
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		return visitImages(pd, custom, func(image string) (string, error) {
+			return rewriteImage(image, rewrites), nil
+		})
+	}
+}
+
+// pinImages returns a step that appends the digest resolved by digests to every
+// image without one. Images with an unknown digest fail the step.
+func pinImages(digests images.DigestResolver, custom []config.ImagePath) step {
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		return visitImages(pd, custom, func(image string) (string, error) {
+			ref := images.ParseReference(image)
+			if ref.Digest != "" {
+				return image, nil
+			}
+
+			digest, err := digests.Digest(ref)
+			if err != nil {
+				return "", fmt.Errorf("pinning image %s: %w", image, err)
+			}
+
+			return image + "@" + digest, nil
+		})
+	}
+}
+
+func rewriteImage(image string, rewrites []config.ImageRewrite) string {
+	ref := images.ParseReference(image)
+
+	var match *config.ImageRewrite
+	for i, rewrite := range rewrites {
+		from := parseImagePrefix(rewrite.From)
+		if ref.Name != from && !strings.HasPrefix(ref.Name, from+"/") {
+			continue
+		}
+
+		if match == nil || len(from) > len(parseImagePrefix(match.From)) {
+			match = &rewrites[i]
+		}
+	}
+
+	if match == nil {
+		return image
+	}
+
+	ref.Name = strings.TrimSuffix(match.To, "/") + strings.TrimPrefix(ref.Name, parseImagePrefix(match.From))
+
+	return ref.String()
+}
+
+// parseImagePrefix normalizes a registry or repository prefix. Prefixes without
+// a registry refer to Docker Hub, so "bitnami" matches "docker.io/bitnami/redis".
+func parseImagePrefix(prefix string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	domain, _, _ := strings.Cut(prefix, "/")
+	if images.IsRegistry(domain) {
+		return prefix
+	}
+
+	return images.DefaultRegistry + "/" + prefix
+}
@@ -0,0 +1,73 @@
+package transform
+
+import (
+	"maps"
+	"slices"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+// podTemplatePaths lists paths to pod template metadata, keyed by the group and
+// kind of resources containing them.
+var podTemplatePaths = map[string][]Path{
+	"/ReplicationController": {mustParsePath("spec.template.metadata")},
+	"apps/DaemonSet":         {mustParsePath("spec.template.metadata")},
+	"apps/Deployment":        {mustParsePath("spec.template.metadata")},
+	"apps/ReplicaSet":        {mustParsePath("spec.template.metadata")},
+	"apps/StatefulSet":       {mustParsePath("spec.template.metadata")},
+	"batch/CronJob": {
+		mustParsePath("spec.jobTemplate.metadata"),
+		mustParsePath("spec.jobTemplate.spec.template.metadata"),
+	},
+	"batch/Job": {mustParsePath("spec.template.metadata")},
+}
+
+// addMetadata returns a step that sets the given labels or annotations (field)
+// on every resource and, if requested, on pod templates of workloads.
+//
+// Selectors are never modified, so adding labels cannot break the link between
+// workloads and their pods.
+func addMetadata(field string, common config.CommonMetadata) step {
+	keys := slices.Sorted(maps.Keys(common.Pairs))
+
+	apply := func(node any) any {
+		values, ok := getKey(node, field)
+		if !ok || !isMap(values) {
+			values = map[string]any{}
+		}
+
+		for _, key := range keys {
+			values = setKey(values, key, common.Pairs[key])
+		}
+
+		return setKey(node, field, values)
+	}
+
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		metadata, ok := getKey(pd.Document, "metadata")
+		if !ok || !isMap(metadata) {
+			metadata = map[string]any{}
+		}
+
+		document, _ := setKey(pd.Document, "metadata", apply(metadata)).(yaml.MapSlice)
+
+		if !common.IncludeTemplates {
+			return document, nil
+		}
+
+		for _, path := range podTemplatePaths[pd.Metadata.Group+"/"+pd.Metadata.Kind] {
+			path.Visit(document, func(metadata any) any {
+				if !isMap(metadata) {
+					return metadata
+				}
+
+				return apply(metadata)
+			})
+		}
+
+		return document, nil
+	}
+}
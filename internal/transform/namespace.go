@@ -0,0 +1,128 @@
+package transform
+
+import (
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/manifest"
+)
+
+const injectCAFromAnnotation = "cert-manager.io/inject-ca-from"
+
+// namespaceReferences lists paths to objects referencing a service by
+// namespace, keyed by the group and kind of resources containing them.
+var namespaceReferences = map[string][]Path{
+	"admissionregistration.k8s.io/MutatingWebhookConfiguration":   {mustParsePath("webhooks[*].clientConfig.service")},
+	"admissionregistration.k8s.io/ValidatingWebhookConfiguration": {mustParsePath("webhooks[*].clientConfig.service")},
+	"apiextensions.k8s.io/CustomResourceDefinition":               {mustParsePath("spec.conversion.webhook.clientConfig.service")},
+	"apiregistration.k8s.io/APIService":                           {mustParsePath("spec.service")},
+}
+
+// setNamespace returns a step that moves namespaced resources to namespace.
+//
+// References to the resources are rewritten as well: ServiceAccount subjects
+// of RoleBindings and ClusterRoleBindings, services of webhooks, CRD conversion
+// webhooks and APIServices, and the cert-manager CA injection annotation. A
+// reference is rewritten if it points to a namespace the resources were
+// rendered in, or to a ServiceAccount, Service or Certificate of the same name
+// in documents, as charts often leave resources without a namespace but
+// hard-code "default" in references. ServiceAccount subjects without a
+// namespace are set to namespace too.
+func setNamespace(namespace string, documents []manifest.ParsedDocument, scopes manifest.Scopes) step {
+	rendered := make(map[string]struct{})
+	names := make(map[string]struct{})
+	for _, pd := range documents {
+		if scopes.IsClusterScoped(pd) || pd.Metadata.Metadata == nil {
+			continue
+		}
+
+		if pd.Metadata.Metadata.Namespace != "" {
+			rendered[pd.Metadata.Metadata.Namespace] = struct{}{}
+		}
+
+		names[pd.Metadata.Group+"/"+pd.Metadata.Kind+"/"+pd.Metadata.Metadata.Name] = struct{}{}
+	}
+
+	// rewrite returns the namespace of a reference to the resource of kind in
+	// group named name, in namespace current.
+	rewrite := func(current, group, kind, name string) string {
+		if _, ok := rendered[current]; ok {
+			return namespace
+		}
+
+		if _, ok := names[group+"/"+kind+"/"+name]; ok {
+			return namespace
+		}
+
+		return current
+	}
+
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		document := pd.Document
+
+		if !scopes.IsClusterScoped(pd) {
+			document = setMetadataField(document, "namespace", namespace)
+		}
+
+		if pd.Metadata.Group == "rbac.authorization.k8s.io" && (pd.Metadata.Kind == "RoleBinding" || pd.Metadata.Kind == "ClusterRoleBinding") {
+			mustParsePath("subjects[*]").Visit(document, func(subject any) any {
+				if getString(subject, "kind") != "ServiceAccount" {
+					return subject
+				}
+
+				current := getString(subject, "namespace")
+				if current == "" {
+					return setKey(subject, "namespace", namespace)
+				}
+
+				return setKey(subject, "namespace", rewrite(current, "", "ServiceAccount", getString(subject, "name")))
+			})
+		}
+
+		for _, path := range namespaceReferences[pd.Metadata.Group+"/"+pd.Metadata.Kind] {
+			path.Visit(document, func(service any) any {
+				current := getString(service, "namespace")
+				if current == "" {
+					return service
+				}
+
+				return setKey(service, "namespace", rewrite(current, "", "Service", getString(service, "name")))
+			})
+		}
+
+		mustParsePath("metadata.annotations").Visit(document, func(annotations any) any {
+			value := getString(annotations, injectCAFromAnnotation)
+
+			current, name, found := strings.Cut(value, "/")
+			if !found {
+				return annotations
+			}
+
+			return setKey(annotations, injectCAFromAnnotation, rewrite(current, "cert-manager.io", "Certificate", name)+"/"+name)
+		})
+
+		return document, nil
+	}
+}
+
+// setMetadataField sets metadata.<key>, creating metadata if it does not exist.
+func setMetadataField(document yaml.MapSlice, key string, value any) yaml.MapSlice {
+	metadata, ok := getKey(document, "metadata")
+	if !ok || !isMap(metadata) {
+		metadata = map[string]any{}
+	}
+
+	document, _ = setKey(document, "metadata", setKey(metadata, key, value)).(yaml.MapSlice)
+
+	return document
+}
+
+func mustParsePath(path string) Path {
+	parsed, err := ParsePath(path)
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
@@ -0,0 +1,70 @@
+package transform
+
+import (
+	yaml "github.com/goccy/go-yaml"
+)
+
+// Decoded documents are yaml.MapSlice at the top level, while nested mappings
+// are decoded as map[string]any. The helpers below operate on both.
+
+// getKey returns the value of key in a mapping node.
+func getKey(node any, key string) (any, bool) {
+	switch node := node.(type) {
+	case yaml.MapSlice:
+		for _, item := range node {
+			if itemKey, ok := item.Key.(string); ok && itemKey == key {
+				return item.Value, true
+			}
+		}
+
+	case map[string]any:
+		value, ok := node[key]
+		return value, ok
+	}
+
+	return nil, false
+}
+
+// setKey sets key in a mapping node and returns the updated node. New keys of
+// yaml.MapSlice are appended at the end.
+func setKey(node any, key string, value any) any {
+	switch node := node.(type) {
+	case yaml.MapSlice:
+		for i, item := range node {
+			if itemKey, ok := item.Key.(string); ok && itemKey == key {
+				node[i].Value = value
+				return node
+			}
+		}
+
+		return append(node, yaml.MapItem{Key: key, Value: value})
+
+	case map[string]any:
+		node[key] = value
+	}
+
+	return node
+}
+
+// removeKey deletes key from a mapping node and returns the updated node.
+func removeKey(node any, key string) any {
+	return deleteKey(node, segment{key: key})
+}
+
+// isMap reports whether node is a mapping node.
+func isMap(node any) bool {
+	switch node.(type) {
+	case yaml.MapSlice, map[string]any:
+		return true
+	}
+
+	return false
+}
+
+// getString returns the string value of key in a mapping node.
+func getString(node any, key string) string {
+	value, _ := getKey(node, key)
+	str, _ := value.(string)
+
+	return str
+}
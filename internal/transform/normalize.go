@@ -0,0 +1,126 @@
+package transform
+
+import (
+	"slices"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/manifest"
+)
+
+// topLevelKeyOrder lists top-level keys written first, in order. Other keys
+// follow sorted alphabetically, with status always last.
+var topLevelKeyOrder = []string{"apiVersion", "kind", "metadata", "spec"}
+
+// meaningfulEmptyKeys are keys whose empty value differs from a missing one,
+// e.g. an empty namespaceSelector matches all namespaces, while a missing one
+// matches none. Empty values of these keys are kept.
+var meaningfulEmptyKeys = map[string]struct{}{
+	"default":           {},
+	"emptyDir":          {},
+	"example":           {},
+	"labelSelector":     {},
+	"namespaceSelector": {},
+	"objectSelector":    {},
+	"podSelector":       {},
+	"selector":          {},
+}
+
+// opaqueKeys hold user data that is written as is.
+var opaqueKeys = map[string]struct{}{
+	"binaryData": {},
+	"data":       {},
+	"stringData": {},
+}
+
+// normalizeDocument returns a step that rewrites a document in canonical form:
+// conventional top-level key order, sorted keys of nested mappings and no null
+// or empty values, except where an empty value is meaningful.
+//
+// Documents encrypted with sops are left untouched, since sops authenticates
+// the document including its key order.
+func normalizeDocument() step {
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		if _, ok := getKey(pd.Document, "sops"); ok {
+			return pd.Document, nil
+		}
+
+		normalized := make(yaml.MapSlice, 0, len(pd.Document))
+		for _, key := range topLevelKeys(pd.Document) {
+			value, _ := getKey(pd.Document, key)
+
+			value, keep := normalizeValue(key, value, false)
+			if !keep {
+				continue
+			}
+
+			normalized = append(normalized, yaml.MapItem{Key: key, Value: value})
+		}
+
+		return normalized, nil
+	}
+}
+
+func topLevelKeys(document yaml.MapSlice) []string {
+	keys := mapKeys(document)
+
+	slices.SortStableFunc(keys, func(a, b string) int {
+		return topLevelKeyRank(a) - topLevelKeyRank(b)
+	})
+
+	return keys
+}
+
+func topLevelKeyRank(key string) int {
+	if index := slices.Index(topLevelKeyOrder, key); index >= 0 {
+		return index
+	}
+
+	if key == "status" {
+		return len(topLevelKeyOrder) + 1
+	}
+
+	return len(topLevelKeyOrder)
+}
+
+// normalizeValue returns the normalized value of key and whether it should be
+// kept. Nested mappings are converted to yaml.MapSlice with sorted keys.
+func normalizeValue(key string, value any, opaque bool) (any, bool) {
+	if _, ok := opaqueKeys[key]; ok {
+		opaque = true
+	}
+
+	_, meaningful := meaningfulEmptyKeys[key]
+
+	switch value := value.(type) {
+	case nil:
+		return nil, false
+
+	case yaml.MapSlice, map[string]any:
+		normalized := yaml.MapSlice{}
+		for _, childKey := range mapKeys(value) {
+			child, _ := getKey(value, childKey)
+
+			child, keep := normalizeValue(childKey, child, opaque)
+			if !keep && !opaque {
+				continue
+			}
+
+			normalized = append(normalized, yaml.MapItem{Key: childKey, Value: child})
+		}
+
+		return normalized, len(normalized) > 0 || meaningful || opaque
+
+	case []any:
+		normalized := make([]any, 0, len(value))
+		for _, item := range value {
+			// list items are kept even if empty, e.g. "ingress: [{}]" allows all traffic
+			item, _ = normalizeValue("", item, opaque)
+			normalized = append(normalized, item)
+		}
+
+		return normalized, len(normalized) > 0 || meaningful || opaque
+	}
+
+	return value, true
+}
@@ -0,0 +1,461 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+// mergeKeys maps list field names to the key identifying their items in
+// strategic merge patches. Lists not listed here are replaced.
+var mergeKeys = map[string]string{
+	"containers":          "name",
+	"ephemeralContainers": "name",
+	"env":                 "name",
+	"imagePullSecrets":    "name",
+	"initContainers":      "name",
+	"ports":               "containerPort",
+	"volumeDevices":       "devicePath",
+	"volumeMounts":        "mountPath",
+	"volumes":             "name",
+}
+
+// jsonPatchOperation is a single JSON 6902 operation.
+type jsonPatchOperation struct {
+	Op    string
+	Path  []string
+	From  []string
+	Value any
+}
+
+// applyPatch returns a step that applies a JSON 6902 or strategic merge patch
+// to documents matching the patch target.
+func applyPatch(patch config.Patch) (step, error) {
+	content, err := patch.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	switch content := content.(type) {
+	case []any:
+		operations, err := parseJSONPatch(content)
+		if err != nil {
+			return nil, err
+		}
+
+		return patchStep(patch.Target, func(document yaml.MapSlice) (yaml.MapSlice, error) {
+			return applyJSONPatch(document, operations)
+		}), nil
+
+	case map[string]any:
+		target := patch.Target
+		if target == nil {
+			target = patchSelector(content)
+
+			if target.Kind == "" || target.Metadata == nil || target.Metadata.Name == "" {
+				return nil, errors.New("strategic merge patch without target must have kind and metadata.name")
+			}
+		}
+
+		return patchStep(target, func(document yaml.MapSlice) (yaml.MapSlice, error) {
+			merged, _ := strategicMerge(document, content, "").(yaml.MapSlice)
+			return merged, nil
+		}), nil
+	}
+
+	return nil, errors.New("patch must be a list of JSON 6902 operations or a strategic merge patch object")
+}
+
+// patchStep wraps fn in a step that only runs for documents matching target.
+func patchStep(target *config.Selector, fn func(document yaml.MapSlice) (yaml.MapSlice, error)) step {
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		if target != nil && !pd.MatchesSelector(*target) {
+			return pd.Document, nil
+		}
+
+		return fn(pd.Document)
+	}
+}
+
+// patchSelector returns a selector matching the resource identified by a
+// strategic merge patch.
+func patchSelector(patch map[string]any) *config.Selector {
+	selector := &config.Selector{
+		APIVersion: getString(patch, "apiVersion"),
+		Kind:       getString(patch, "kind"),
+	}
+
+	if metadata, ok := getKey(patch, "metadata"); ok {
+		selector.Metadata = &config.MetadataSelector{
+			Name:      getString(metadata, "name"),
+			Namespace: getString(metadata, "namespace"),
+		}
+	}
+
+	return selector
+}
+
+func parseJSONPatch(content []any) ([]jsonPatchOperation, error) {
+	operations := make([]jsonPatchOperation, 0, len(content))
+
+	for i, item := range content {
+		op := getString(item, "op")
+
+		path, err := parsePointer(getString(item, "path"))
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: path: %w", i, err)
+		}
+
+		operation := jsonPatchOperation{Op: op, Path: path}
+
+		switch op {
+		case "add", "replace", "test":
+			value, ok := getKey(item, "value")
+			if !ok {
+				return nil, fmt.Errorf("operation %d: %s requires value", i, op)
+			}
+
+			operation.Value = value
+
+		case "move", "copy":
+			from, err := parsePointer(getString(item, "from"))
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: from: %w", i, err)
+			}
+
+			operation.From = from
+
+		case "remove":
+			// no fields besides path
+
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i, op)
+		}
+
+		operations = append(operations, operation)
+	}
+
+	return operations, nil
+}
+
+// parsePointer parses a JSON pointer (RFC 6901) into its reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+func applyJSONPatch(document yaml.MapSlice, operations []jsonPatchOperation) (yaml.MapSlice, error) {
+	var node any = document
+
+	for i, operation := range operations {
+		var err error
+
+		switch operation.Op {
+		case "add":
+			node, err = addValue(node, operation.Path, manifest.CloneValue(operation.Value))
+
+		case "remove":
+			node, err = updateIn(node, operation.Path, removeChild)
+
+		case "replace":
+			node, err = updateIn(node, operation.Path, func(parent any, key string) (any, error) {
+				if _, ok := getChild(parent, key); !ok {
+					return nil, fmt.Errorf("path not found: %q", key)
+				}
+
+				return setChild(parent, key, manifest.CloneValue(operation.Value))
+			})
+
+		case "move":
+			var value any
+
+			value, err = getIn(node, operation.From)
+			if err == nil {
+				node, err = updateIn(node, operation.From, removeChild)
+			}
+
+			if err == nil {
+				node, err = addValue(node, operation.Path, value)
+			}
+
+		case "copy":
+			var value any
+
+			value, err = getIn(node, operation.From)
+			if err == nil {
+				node, err = addValue(node, operation.Path, manifest.CloneValue(value))
+			}
+
+		case "test":
+			var value any
+
+			value, err = getIn(node, operation.Path)
+			if err == nil && !reflect.DeepEqual(normalizeNumber(value), normalizeNumber(operation.Value)) {
+				err = fmt.Errorf("test failed: value is %v, expected %v", value, operation.Value)
+			}
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s /%s): %w", i, operation.Op, strings.Join(operation.Path, "/"), err)
+		}
+	}
+
+	document, _ = node.(yaml.MapSlice)
+
+	return document, nil
+}
+
+func addValue(node any, path []string, value any) (any, error) {
+	return updateIn(node, path, func(parent any, key string) (any, error) {
+		list, ok := parent.([]any)
+		if !ok {
+			return setChild(parent, key, value)
+		}
+
+		if key == "-" {
+			return append(list, value), nil
+		}
+
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index > len(list) {
+			return nil, fmt.Errorf("invalid list index %q", key)
+		}
+
+		return slices.Insert(list, index, value), nil
+	})
+}
+
+func removeChild(parent any, key string) (any, error) {
+	if _, ok := getChild(parent, key); !ok {
+		return nil, fmt.Errorf("path not found: %q", key)
+	}
+
+	if list, ok := parent.([]any); ok {
+		index, _ := strconv.Atoi(key)
+		return slices.Delete(list, index, index+1), nil
+	}
+
+	return removeKey(parent, key), nil
+}
+
+// updateIn navigates to the parent of the value at path and replaces it with the result of fn.
+func updateIn(node any, path []string, fn func(parent any, key string) (any, error)) (any, error) {
+	if len(path) == 0 {
+		return nil, errors.New("patching the document root is not supported")
+	}
+
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+
+	child, ok := getChild(node, path[0])
+	if !ok {
+		return nil, fmt.Errorf("path not found: %q", path[0])
+	}
+
+	updated, err := updateIn(child, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+
+	return setChild(node, path[0], updated)
+}
+
+func getIn(node any, path []string) (any, error) {
+	for _, key := range path {
+		child, ok := getChild(node, key)
+		if !ok {
+			return nil, fmt.Errorf("path not found: %q", key)
+		}
+
+		node = child
+	}
+
+	return node, nil
+}
+
+func getChild(node any, key string) (any, bool) {
+	if list, ok := node.([]any); ok {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(list) {
+			return nil, false
+		}
+
+		return list[index], true
+	}
+
+	return getKey(node, key)
+}
+
+func setChild(node any, key string, value any) (any, error) {
+	if list, ok := node.([]any); ok {
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(list) {
+			return nil, fmt.Errorf("invalid list index %q", key)
+		}
+
+		list[index] = value
+
+		return list, nil
+	}
+
+	if !isMap(node) {
+		return nil, fmt.Errorf("cannot set %q on a scalar value", key)
+	}
+
+	return setKey(node, key, value), nil
+}
+
+// strategicMerge merges patch into node following the strategic merge patch
+// rules for the subset of Kubernetes list merge keys in mergeKeys:
+//
+//   - null values delete the key;
+//   - "$patch: delete" deletes a mapping or list item, "$patch: replace" replaces it;
+//   - lists with a known merge key are merged item by item, other lists are replaced.
+func strategicMerge(node any, patch any, field string) any {
+	switch patch := patch.(type) {
+	case map[string]any:
+		directive, _ := patch["$patch"].(string)
+		switch directive {
+		case "delete":
+			return nil
+		case "replace":
+			return withoutDirectives(patch)
+		}
+
+		if !isMap(node) {
+			return withoutDirectives(patch)
+		}
+
+		for _, key := range slices.Sorted(maps.Keys(patch)) {
+			if strings.HasPrefix(key, "$") {
+				continue
+			}
+
+			value := patch[key]
+			if value == nil {
+				node = removeKey(node, key)
+				continue
+			}
+
+			current, _ := getKey(node, key)
+
+			merged := strategicMerge(current, value, key)
+			if merged == nil {
+				node = removeKey(node, key)
+				continue
+			}
+
+			node = setKey(node, key, merged)
+		}
+
+		return node
+
+	case []any:
+		list, ok := node.([]any)
+		if !ok || mergeKeys[field] == "" {
+			return manifest.CloneValue(patch)
+		}
+
+		return mergeList(list, patch, field)
+	}
+
+	return patch
+}
+
+func mergeList(list []any, patch []any, field string) []any {
+	for _, item := range patch {
+		key, value, ok := mergeKeyValue(item, field)
+		if !ok {
+			list = append(list, manifest.CloneValue(item))
+			continue
+		}
+
+		index := slices.IndexFunc(list, func(existing any) bool {
+			existingValue, _ := getKey(existing, key)
+			return reflect.DeepEqual(normalizeNumber(existingValue), normalizeNumber(value))
+		})
+
+		if index < 0 {
+			if directive, _ := getKey(item, "$patch"); directive != "delete" {
+				list = append(list, withoutDirectives(item.(map[string]any)))
+			}
+
+			continue
+		}
+
+		merged := strategicMerge(list[index], item, "")
+		if merged == nil {
+			list = slices.Delete(list, index, index+1)
+			continue
+		}
+
+		list[index] = merged
+	}
+
+	return list
+}
+
+// mergeKeyValue returns the merge key of a list item and its value.
+func mergeKeyValue(item any, field string) (string, any, bool) {
+	key := mergeKeys[field]
+
+	// service ports are identified by port rather than containerPort
+	if field == "ports" {
+		if _, ok := getKey(item, key); !ok {
+			key = "port"
+		}
+	}
+
+	value, ok := getKey(item, key)
+
+	return key, value, ok
+}
+
+func withoutDirectives(patch map[string]any) map[string]any {
+	result := make(map[string]any, len(patch))
+	for key, value := range patch {
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+
+		if value, ok := value.(map[string]any); ok {
+			result[key] = withoutDirectives(value)
+			continue
+		}
+
+		result[key] = manifest.CloneValue(value)
+	}
+
+	return result
+}
+
+// normalizeNumber converts integers to int64 so values decoded with different
+// integer types compare equal.
+func normalizeNumber(value any) any {
+	switch value := value.(type) {
+	case int:
+		return int64(value)
+	case uint64:
+		return int64(value)
+	}
+
+	return value
+}
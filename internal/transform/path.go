@@ -0,0 +1,185 @@
+package transform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+)
+
+// Path is a parsed field path such as "metadata.labels[helm.sh/chart]".
+//
+// Segments are separated by dots. A segment in square brackets is taken
+// literally, which allows keys containing dots or slashes. The "[*]" segment
+// matches every item of a list or every value of a map, and "[N]" selects the
+// N-th item of a list.
+type Path []segment
+
+type segment struct {
+	key      string
+	wildcard bool
+}
+
+// ParsePath parses a field path.
+func ParsePath(path string) (Path, error) {
+	var segments Path
+
+	rest := path
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("parsing path %q: missing closing bracket", path)
+			}
+
+			key := rest[1:end]
+			if key == "" {
+				return nil, fmt.Errorf("parsing path %q: empty segment", path)
+			}
+
+			segments = append(segments, segment{key: key, wildcard: key == "*"})
+			rest = rest[end+1:]
+
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+
+			if end == 0 {
+				return nil, fmt.Errorf("parsing path %q: empty segment", path)
+			}
+
+			segments = append(segments, segment{key: rest[:end]})
+			rest = rest[end:]
+		}
+
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" {
+				return nil, fmt.Errorf("parsing path %q: empty segment", path)
+			}
+		}
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("parsing path %q: empty path", path)
+	}
+
+	return segments, nil
+}
+
+// Remove deletes the value at the path from node and returns the updated node.
+// Missing intermediate fields are ignored.
+func (p Path) Remove(node any) any {
+	if len(p) == 0 {
+		return node
+	}
+
+	head, tail := p[0], p[1:]
+
+	if len(tail) == 0 {
+		return deleteKey(node, head)
+	}
+
+	return p.updateChildren(node, func(child any) any {
+		return tail.Remove(child)
+	})
+}
+
+// Visit calls fn for every value matched by the path. The value returned by fn
+// replaces the visited value. The updated node is returned.
+func (p Path) Visit(node any, fn func(value any) any) any {
+	if len(p) == 0 {
+		return fn(node)
+	}
+
+	tail := p[1:]
+
+	return p.updateChildren(node, func(child any) any {
+		return tail.Visit(child, fn)
+	})
+}
+
+// updateChildren applies fn to the children of node selected by the first segment.
+func (p Path) updateChildren(node any, fn func(child any) any) any {
+	head := p[0]
+
+	switch node := node.(type) {
+	case yaml.MapSlice:
+		for i, item := range node {
+			key, ok := item.Key.(string)
+			if !ok || (!head.wildcard && key != head.key) {
+				continue
+			}
+
+			node[i].Value = fn(item.Value)
+		}
+
+	case map[string]any:
+		for key, value := range node {
+			if !head.wildcard && key != head.key {
+				continue
+			}
+
+			node[key] = fn(value)
+		}
+
+	case []any:
+		if head.wildcard {
+			for i, item := range node {
+				node[i] = fn(item)
+			}
+
+			return node
+		}
+
+		index, err := strconv.Atoi(head.key)
+		if err != nil || index < 0 || index >= len(node) {
+			return node
+		}
+
+		node[index] = fn(node[index])
+	}
+
+	return node
+}
+
+func deleteKey(node any, key segment) any {
+	switch node := node.(type) {
+	case yaml.MapSlice:
+		result := node[:0]
+		for _, item := range node {
+			if itemKey, ok := item.Key.(string); ok && (key.wildcard || itemKey == key.key) {
+				continue
+			}
+
+			result = append(result, item)
+		}
+
+		return result
+
+	case map[string]any:
+		for itemKey := range node {
+			if key.wildcard || itemKey == key.key {
+				delete(node, itemKey)
+			}
+		}
+
+	case []any:
+		if key.wildcard {
+			return node[:0]
+		}
+
+		index, err := strconv.Atoi(key.key)
+		if err != nil || index < 0 || index >= len(node) {
+			return node
+		}
+
+		return append(node[:index], node[index+1:]...)
+	}
+
+	return node
+}
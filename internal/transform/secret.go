@@ -0,0 +1,213 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/commandexec"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+const defaultSecretPlaceholder = "REDACTED"
+
+// secretDataFields are the fields of a Secret holding its values. Values of
+// data are base64 encoded, values of stringData are not.
+var secretDataFields = []string{"data", "stringData"}
+
+// handleSecrets returns a step that applies the secrets policy to Secrets.
+// previous are the documents written to the target by the previous run.
+func handleSecrets(secrets config.Secrets, executor commandexec.CommandExecutor, previous []manifest.ParsedDocument) step {
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		if pd.Metadata.Group != "" || pd.Metadata.Kind != "Secret" {
+			return pd.Document, nil
+		}
+
+		switch secrets.Policy {
+		case config.SecretPolicyRedact:
+			return redactSecret(pd.Document, secrets.Placeholder), nil
+
+		case config.SecretPolicyDropData:
+			document := pd.Document
+			for _, field := range secretDataFields {
+				document, _ = removeKey(document, field).(yaml.MapSlice)
+			}
+
+			return document, nil
+
+		case config.SecretPolicyEncrypt:
+			return encryptSecret(executor, pd, secrets.Age, previous)
+		}
+
+		return pd.Document, nil
+	}
+}
+
+// redactSecret replaces every value of a Secret with placeholder, keeping the keys.
+func redactSecret(document yaml.MapSlice, placeholder string) yaml.MapSlice {
+	if placeholder == "" {
+		placeholder = defaultSecretPlaceholder
+	}
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(placeholder))
+
+	for _, field := range secretDataFields {
+		values, ok := getKey(document, field)
+		if !ok || !isMap(values) {
+			continue
+		}
+
+		value := placeholder
+		if field == "data" {
+			value = encoded
+		}
+
+		redacted := make(map[string]any)
+		for _, key := range mapKeys(values) {
+			redacted[key] = value
+		}
+
+		document, _ = setKey(document, field, redacted).(yaml.MapSlice)
+	}
+
+	return document
+}
+
+// encryptSecret encrypts data and stringData of a Secret with sops for the age recipients.
+//
+// sops output differs on every run, so if the Secret written by the previous
+// run decrypts to the same document, it is kept as it is. If it cannot be
+// decrypted, for example because the age identity is not available, the
+// Secret is encrypted again.
+func encryptSecret(executor commandexec.CommandExecutor, pd manifest.ParsedDocument, recipients []string, previous []manifest.ParsedDocument) (yaml.MapSlice, error) {
+	if executor == nil {
+		return nil, fmt.Errorf("encrypting secret: no command executor configured")
+	}
+
+	if _, err := executor.LookPath("sops"); err != nil {
+		return nil, fmt.Errorf("sops not found in PATH: %w. Please install sops", err)
+	}
+
+	content, err := manifest.Marshal(pd.Document)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling secret: %w", err)
+	}
+
+	if encrypted, ok := previousSecret(pd, previous); ok {
+		if decrypted, err := runSops(executor, encrypted, "--decrypt"); err == nil && sameDocument(decrypted, content) {
+			return encrypted, nil
+		}
+	}
+
+	output, err := runSops(executor, pd.Document, "--encrypt",
+		"--age", strings.Join(recipients, ","),
+		"--encrypted-regex", "^(data|stringData)$",
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var encrypted yaml.MapSlice
+	if err := yaml.Unmarshal(output, &encrypted); err != nil {
+		return nil, fmt.Errorf("parsing sops output: %w", err)
+	}
+
+	return encrypted, nil
+}
+
+// previousSecret returns the sops encrypted Secret in previous with the same
+// name and namespace as pd.
+func previousSecret(pd manifest.ParsedDocument, previous []manifest.ParsedDocument) (yaml.MapSlice, bool) {
+	for _, candidate := range previous {
+		if candidate.String() != pd.String() {
+			continue
+		}
+
+		if _, ok := getKey(candidate.Document, "sops"); ok {
+			return candidate.Document, true
+		}
+	}
+
+	return nil, false
+}
+
+// sameDocument reports whether the YAML document in output encodes to content.
+func sameDocument(output, content []byte) bool {
+	documents, err := manifest.ParseDocuments(output)
+	if err != nil || len(documents) != 1 {
+		return false
+	}
+
+	encoded, err := manifest.Marshal(documents[0].Document)
+
+	return err == nil && bytes.Equal(encoded, content)
+}
+
+// runSops runs sops with args on document and returns its output.
+//
+// sops reads its input from a file, so the document is written to a temporary
+// file readable only by the current user and removed right after sops exits.
+func runSops(executor commandexec.CommandExecutor, document yaml.MapSlice, args ...string) ([]byte, error) {
+	content, err := manifest.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling secret: %w", err)
+	}
+
+	file, err := os.CreateTemp("", "kubesource-secret-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("writing temporary file: %w", err)
+	}
+
+	args = append(args, "--input-type", "yaml", "--output-type", "yaml", file.Name())
+
+	output, err := executor.Exec("sops", args...)
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("sops failed: %s\nStderr: %s", err, string(exitError.Stderr))
+		}
+
+		return nil, fmt.Errorf("sops failed: %w", err)
+	}
+
+	return output, nil
+}
+
+// mapKeys returns sorted keys of a mapping node.
+func mapKeys(node any) []string {
+	switch node := node.(type) {
+	case yaml.MapSlice:
+		keys := make([]string, 0, len(node))
+		for _, item := range node {
+			if key, ok := item.Key.(string); ok {
+				keys = append(keys, key)
+			}
+		}
+
+		slices.Sort(keys)
+
+		return keys
+
+	case map[string]any:
+		return slices.Sorted(maps.Keys(node))
+	}
+
+	return nil
+}
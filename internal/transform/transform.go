@@ -0,0 +1,204 @@
+package transform
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	yaml "github.com/goccy/go-yaml"
+
+	"github.com/artuross/kubesource/internal/images"
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/pkg/commandexec"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+// step transforms a single document. Steps may modify the document in place.
+// A step returning a nil document removes the document from the output.
+type step func(pd manifest.ParsedDocument) (yaml.MapSlice, error)
+
+// Options holds data transformations need besides the target configuration.
+type Options struct {
+	// SourceDocuments are all documents rendered from the source, including
+	// those not written to the target. They are used to look up related
+	// resources, such as CustomResourceDefinitions of custom resources.
+	SourceDocuments []manifest.ParsedDocument
+
+	// Digests resolves image digests when the target pins images to digests.
+	Digests images.DigestResolver
+
+	// Executor runs external tools, such as sops to encrypt Secrets.
+	Executor commandexec.CommandExecutor
+
+	// PreviousDocuments are the documents written to the target by the
+	// previous run. Secrets encrypted by that run are kept if their content
+	// did not change, as sops output differs on every run.
+	PreviousDocuments []manifest.ParsedDocument
+
+	// Out receives reports of transformations, such as sizes of
+	// CustomResourceDefinitions before and after descriptions are stripped.
+	Out io.Writer
+}
+
+// Apply returns copies of documents with the transformations configured for
+// target applied. The input documents are not modified.
+func Apply(target config.Target, documents []manifest.ParsedDocument, opts Options) ([]manifest.ParsedDocument, error) {
+	steps, err := buildSteps(target, documents, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(steps) == 0 {
+		return documents, nil
+	}
+
+	transformed := make([]manifest.ParsedDocument, 0, len(documents))
+	for i, pd := range documents {
+		document, err := applySteps(steps, pd.Clone())
+		if err != nil {
+			return nil, fmt.Errorf("transforming document %d (%s): %w", i, pd, err)
+		}
+
+		if document.Document == nil {
+			continue
+		}
+
+		transformed = append(transformed, document)
+	}
+
+	return transformed, nil
+}
+
+// applySteps runs steps on a document. The returned document is empty if a step removed it.
+func applySteps(steps []step, document manifest.ParsedDocument) (manifest.ParsedDocument, error) {
+	for _, step := range steps {
+		updated, err := step(document)
+		if err != nil {
+			return manifest.ParsedDocument{}, err
+		}
+
+		if updated == nil {
+			return manifest.ParsedDocument{}, nil
+		}
+
+		document = manifest.NewParsedDocument(updated)
+	}
+
+	return document, nil
+}
+
+func buildSteps(target config.Target, documents []manifest.ParsedDocument, opts Options) ([]step, error) {
+	var steps []step
+
+	if target.Transform != nil {
+		transformSteps, err := buildTransformSteps(*target.Transform, opts.Out)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, transformSteps...)
+	}
+
+	if target.Hooks != "" && target.Hooks != config.HookPolicyKeep {
+		steps = append(steps, handleHooks(target.Hooks))
+	}
+
+	if target.Namespace != "" {
+		steps = append(steps, setNamespace(target.Namespace, documents, manifest.NewScopes(opts.SourceDocuments)))
+	}
+
+	if target.Images != nil {
+		imageSteps, err := buildImageSteps(*target.Images, opts.Digests)
+		if err != nil {
+			return nil, err
+		}
+
+		steps = append(steps, imageSteps...)
+	}
+
+	if target.Labels != nil {
+		steps = append(steps, addMetadata("labels", *target.Labels))
+	}
+
+	if target.Annotations != nil {
+		steps = append(steps, addMetadata("annotations", *target.Annotations))
+	}
+
+	if target.Normalize {
+		steps = append(steps, normalizeDocument())
+	}
+
+	// sops authenticates the whole document, so Secrets are encrypted last
+	if target.Secrets != nil && target.Secrets.Policy != config.SecretPolicyKeep {
+		steps = append(steps, handleSecrets(*target.Secrets, opts.Executor, opts.PreviousDocuments))
+	}
+
+	return steps, nil
+}
+
+func buildImageSteps(cfg config.Images, digests images.DigestResolver) ([]step, error) {
+	for i, rewrite := range cfg.Rewrite {
+		if rewrite.From == "" || rewrite.To == "" {
+			return nil, fmt.Errorf("images.rewrite[%d]: from and to are required", i)
+		}
+	}
+
+	for i, imagePath := range cfg.Paths {
+		if _, err := ParsePath(imagePath.Path); err != nil {
+			return nil, fmt.Errorf("images.paths[%d]: %w", i, err)
+		}
+	}
+
+	var steps []step
+
+	if len(cfg.Rewrite) > 0 {
+		steps = append(steps, rewriteImages(cfg.Rewrite, cfg.Paths))
+	}
+
+	if cfg.Digests != nil {
+		if digests == nil {
+			return nil, errors.New("images.digests: no digest resolver configured")
+		}
+
+		steps = append(steps, pinImages(digests, cfg.Paths))
+	}
+
+	return steps, nil
+}
+
+func buildTransformSteps(transform config.Transform, out io.Writer) ([]step, error) {
+	var steps []step
+
+	for i, field := range transform.Remove {
+		path, err := ParsePath(field)
+		if err != nil {
+			return nil, fmt.Errorf("transform.remove[%d]: %w", i, err)
+		}
+
+		steps = append(steps, removeField(path))
+	}
+
+	for i, patch := range transform.Patches {
+		step, err := applyPatch(patch)
+		if err != nil {
+			return nil, fmt.Errorf("transform.patches[%d]: %w", i, err)
+		}
+
+		steps = append(steps, step)
+	}
+
+	if transform.CRDDescriptions != "" && transform.CRDDescriptions != config.CRDDescriptionsKeep {
+		steps = append(steps, stripCRDDescriptions(transform.CRDDescriptions, out))
+	}
+
+	return steps, nil
+}
+
+// removeField returns a step that deletes the field at path.
+func removeField(path Path) step {
+	return func(pd manifest.ParsedDocument) (yaml.MapSlice, error) {
+		document, _ := path.Remove(pd.Document).(yaml.MapSlice)
+
+		return document, nil
+	}
+}
@@ -0,0 +1,1030 @@
+package transform_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	yaml "github.com/goccy/go-yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/internal/images"
+	"github.com/artuross/kubesource/internal/manifest"
+	"github.com/artuross/kubesource/internal/transform"
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func TestParsePath(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		paths := []string{
+			"status",
+			"metadata.creationTimestamp",
+			"metadata.labels[helm.sh/chart]",
+			"spec.template.spec.containers[*].resources",
+			"spec.ports[0]",
+		}
+
+		for _, path := range paths {
+			t.Run(path, func(t *testing.T) {
+				_, err := transform.ParsePath(path)
+				require.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		paths := []string{
+			"",
+			"metadata.",
+			".metadata",
+			"metadata..name",
+			"metadata.labels[helm.sh/chart",
+			"metadata.labels[]",
+		}
+
+		for _, path := range paths {
+			t.Run(path, func(t *testing.T) {
+				_, err := transform.ParsePath(path)
+				require.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestApply(t *testing.T) {
+	t.Run("remove", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  creationTimestamp: null
+  labels:
+    app: web
+    helm.sh/chart: web-1.0.0
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          resources: {}
+status: {}
+`)
+
+		target := config.Target{
+			Transform: &config.Transform{
+				Remove: []string{
+					"status",
+					"metadata.creationTimestamp",
+					"metadata.labels[helm.sh/chart]",
+					"spec.template.spec.containers[*].resources",
+					"spec.missing.field",
+				},
+			},
+		}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{SourceDocuments: documents})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    app: web
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+`, marshal(t, transformed[0]))
+
+		assert.Contains(t, marshal(t, documents[0]), "helm.sh/chart", "input documents must not be modified")
+	})
+
+	t.Run("JSON 6902 patch", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+        - name: web
+          args: [--port=80]
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`)
+
+		target := config.Target{
+			Transform: &config.Transform{
+				Patches: []config.Patch{
+					{
+						Target: &config.Selector{Kind: "Deployment"},
+						Patch: `
+- op: test
+  path: /spec/replicas
+  value: 1
+- op: replace
+  path: /spec/replicas
+  value: 3
+- op: add
+  path: /spec/template/spec/containers/0/args/-
+  value: --verbose
+- op: add
+  path: /metadata/annotations
+  value:
+    example.com~1owner: platform
+- op: copy
+  from: /metadata/name
+  path: /spec/template/spec/serviceAccountName
+`,
+					},
+				},
+			},
+		}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{SourceDocuments: documents})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    example.com~1owner: platform
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - args:
+        - --port=80
+        - --verbose
+        name: web
+      serviceAccountName: web
+`, marshal(t, transformed[0]))
+		assert.Equal(t, marshal(t, documents[1]), marshal(t, transformed[1]))
+	})
+
+	t.Run("JSON 6902 patch failing test", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`)
+
+		target := config.Target{
+			Transform: &config.Transform{
+				Patches: []config.Patch{{Patch: `[{op: test, path: /metadata/name, value: other}]`}},
+			},
+		}
+
+		_, err := transform.Apply(target, documents, transform.Options{SourceDocuments: documents})
+		require.ErrorContains(t, err, "test failed")
+	})
+
+	t.Run("strategic merge patch", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: nginx
+          env:
+            - name: DEBUG
+              value: "true"
+            - name: MODE
+              value: dev
+        - name: sidecar
+          image: busybox
+      tolerations:
+        - key: a
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: other
+`)
+
+		target := config.Target{
+			Transform: &config.Transform{
+				Patches: []config.Patch{
+					{
+						Patch: `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          env:
+            - name: DEBUG
+              $patch: delete
+            - name: MODE
+              value: prod
+        - name: sidecar
+          $patch: delete
+      tolerations:
+        - key: b
+      priorityClassName: high
+`,
+					},
+				},
+			},
+		}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{SourceDocuments: documents})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - env:
+        - name: MODE
+          value: prod
+        image: nginx
+        name: web
+      priorityClassName: high
+      tolerations:
+      - key: b
+`, marshal(t, transformed[0]))
+		assert.Equal(t, marshal(t, documents[1]), marshal(t, transformed[1]))
+	})
+
+	t.Run("namespace", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: controller
+  namespace: default
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: controller
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: controller
+subjects:
+  - kind: ServiceAccount
+    name: controller
+    namespace: default
+  - kind: ServiceAccount
+    name: other
+    namespace: kube-system
+  - kind: Group
+    name: admins
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: controller
+  annotations:
+    cert-manager.io/inject-ca-from: default/controller-tls
+webhooks:
+  - name: validate.example.com
+    clientConfig:
+      service:
+        name: controller
+        namespace: default
+---
+apiVersion: example.com/v1
+kind: ClusterWidget
+metadata:
+  name: global
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: local
+`)
+
+		crds := parseDocuments(t, `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: clusterwidgets.example.com
+spec:
+  group: example.com
+  scope: Cluster
+  names:
+    kind: ClusterWidget
+`)
+
+		target := config.Target{Namespace: "platform"}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{SourceDocuments: append(documents, crds...)})
+		require.NoError(t, err)
+
+		namespaces := make([]string, 0, len(transformed))
+		for _, pd := range transformed {
+			namespace := ""
+			if pd.Metadata.Metadata != nil {
+				namespace = pd.Metadata.Metadata.Namespace
+			}
+
+			namespaces = append(namespaces, namespace)
+		}
+
+		assert.Equal(t, []string{"platform", "platform", "", "", "", "", "platform"}, namespaces)
+
+		assert.Equal(t, `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: controller
+subjects:
+- kind: ServiceAccount
+  name: controller
+  namespace: platform
+- kind: ServiceAccount
+  name: other
+  namespace: kube-system
+- kind: Group
+  name: admins
+`, marshal(t, transformed[3]))
+
+		assert.Equal(t, `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  annotations:
+    cert-manager.io/inject-ca-from: platform/controller-tls
+  name: controller
+webhooks:
+- clientConfig:
+    service:
+      name: controller
+      namespace: platform
+  name: validate.example.com
+`, marshal(t, transformed[4]))
+	})
+
+	t.Run("namespace of resources rendered without one", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: controller
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: webhook
+---
+apiVersion: cert-manager.io/v1
+kind: Certificate
+metadata:
+  name: webhook-tls
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: controller
+subjects:
+  - kind: ServiceAccount
+    name: controller
+    namespace: default
+  - kind: ServiceAccount
+    name: other
+    namespace: default
+---
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: controller
+  annotations:
+    cert-manager.io/inject-ca-from: default/webhook-tls
+webhooks:
+  - name: validate.example.com
+    clientConfig:
+      service:
+        name: webhook
+        namespace: default
+  - name: external.example.com
+    clientConfig:
+      service:
+        name: external
+        namespace: default
+`)
+
+		target := config.Target{Namespace: "prod"}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{SourceDocuments: documents})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: controller
+subjects:
+- kind: ServiceAccount
+  name: controller
+  namespace: prod
+- kind: ServiceAccount
+  name: other
+  namespace: default
+`, marshal(t, transformed[3]))
+
+		assert.Equal(t, `apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  annotations:
+    cert-manager.io/inject-ca-from: prod/webhook-tls
+  name: controller
+webhooks:
+- clientConfig:
+    service:
+      name: webhook
+      namespace: prod
+  name: validate.example.com
+- clientConfig:
+    service:
+      name: external
+      namespace: default
+  name: external.example.com
+`, marshal(t, transformed[4]))
+	})
+
+	t.Run("labels and annotations", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+`)
+
+		target := config.Target{
+			Labels: &config.CommonMetadata{
+				Pairs:            map[string]string{"team": "platform"},
+				IncludeTemplates: true,
+			},
+			Annotations: &config.CommonMetadata{
+				Pairs: map[string]string{"argocd.argoproj.io/sync-options": "Prune=false"},
+			},
+		}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{SourceDocuments: documents})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    argocd.argoproj.io/sync-options: Prune=false
+  labels:
+    app: web
+    team: platform
+  name: web
+spec:
+  selector:
+    matchLabels:
+      app: web
+  template:
+    metadata:
+      labels:
+        app: web
+        team: platform
+`, marshal(t, transformed[0]))
+
+		assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  annotations:
+    argocd.argoproj.io/sync-options: Prune=false
+  labels:
+    team: platform
+  name: settings
+`, marshal(t, transformed[1]))
+	})
+
+	t.Run("image rewrite", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          initContainers:
+            - name: init
+              image: busybox
+          containers:
+            - name: cleanup
+              image: quay.io/jetstack/cert-manager-ctl:v1.14.0
+            - name: sidecar
+              image: registry.k8s.io/pause:3.9@sha256:abc
+            - name: local
+              image: localhost:5000/tool:1
+---
+apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+metadata:
+  name: main
+spec:
+  image: bitnami/prometheus:2.50
+`)
+
+		target := config.Target{
+			Images: &config.Images{
+				Rewrite: []config.ImageRewrite{
+					{From: "docker.io", To: "mirror.example.com/docker.io"},
+					{From: "docker.io/bitnami", To: "mirror.example.com/bitnami/"},
+					{From: "quay.io", To: "mirror.example.com/quay.io"},
+					{From: "registry.k8s.io", To: "mirror.example.com/k8s"},
+				},
+				Paths: []config.ImagePath{
+					{Target: config.Selector{Kind: "Prometheus"}, Path: "spec.image"},
+				},
+			},
+		}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{SourceDocuments: documents})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - image: mirror.example.com/quay.io/jetstack/cert-manager-ctl:v1.14.0
+            name: cleanup
+          - image: mirror.example.com/k8s/pause:3.9@sha256:abc
+            name: sidecar
+          - image: localhost:5000/tool:1
+            name: local
+          initContainers:
+          - image: mirror.example.com/docker.io/library/busybox
+            name: init
+`, marshal(t, transformed[0]))
+
+		assert.Equal(t, `apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+metadata:
+  name: main
+spec:
+  image: mirror.example.com/bitnami/prometheus:2.50
+`, marshal(t, transformed[1]))
+	})
+
+	t.Run("image digests", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+    - name: web
+      image: nginx:1.25
+    - name: pinned
+      image: busybox@sha256:2222
+`)
+
+		target := config.Target{
+			Images: &config.Images{
+				Rewrite: []config.ImageRewrite{{From: "docker.io", To: "mirror.example.com"}},
+				Digests: &config.ImageDigests{File: "digests.yaml"},
+			},
+		}
+
+		digests := images.DigestMap{"mirror.example.com/library/nginx:1.25": "sha256:1111"}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{Digests: digests})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: v1
+kind: Pod
+metadata:
+  name: web
+spec:
+  containers:
+  - image: mirror.example.com/library/nginx:1.25@sha256:1111
+    name: web
+  - image: mirror.example.com/library/busybox@sha256:2222
+    name: pinned
+`, marshal(t, transformed[0]))
+
+		_, err = transform.Apply(target, documents, transform.Options{Digests: images.DigestMap{}})
+		require.ErrorIs(t, err, images.ErrDigestNotFound)
+	})
+
+	t.Run("secrets", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: c2VjcmV0
+stringData:
+  username: admin
+type: Opaque
+`)
+
+		tests := []struct {
+			name     string
+			secrets  config.Secrets
+			expected string
+		}{
+			{
+				name:    "keep",
+				secrets: config.Secrets{Policy: config.SecretPolicyKeep},
+				expected: `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: c2VjcmV0
+stringData:
+  username: admin
+type: Opaque
+`,
+			},
+			{
+				name:    "redact",
+				secrets: config.Secrets{Policy: config.SecretPolicyRedact},
+				expected: `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: UkVEQUNURUQ=
+stringData:
+  username: REDACTED
+type: Opaque
+`,
+			},
+			{
+				name:    "drop data",
+				secrets: config.Secrets{Policy: config.SecretPolicyDropData},
+				expected: `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+type: Opaque
+`,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				target := config.Target{Secrets: &tt.secrets}
+
+				transformed, err := transform.Apply(target, documents, transform.Options{})
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.expected, marshal(t, transformed[0]))
+			})
+		}
+	})
+
+	t.Run("encrypted secrets", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: c2VjcmV0
+`)
+
+		executor := &fakeSops{}
+		target := config.Target{Secrets: &config.Secrets{Policy: config.SecretPolicyEncrypt, Age: []string{"age1example"}}}
+
+		first, err := transform.Apply(target, documents, transform.Options{Executor: executor})
+		require.NoError(t, err)
+
+		expected := `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: c2VjcmV0
+sops:
+  nonce: 1
+`
+		assert.Equal(t, expected, marshal(t, first[0]))
+
+		// unchanged Secrets keep the output of the previous run
+		second, err := transform.Apply(target, documents, transform.Options{Executor: executor, PreviousDocuments: first})
+		require.NoError(t, err)
+		assert.Equal(t, expected, marshal(t, second[0]))
+
+		changed := parseDocuments(t, `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: Y2hhbmdlZA==
+`)
+
+		third, err := transform.Apply(target, changed, transform.Options{Executor: executor, PreviousDocuments: first})
+		require.NoError(t, err)
+		assert.Equal(t, `apiVersion: v1
+kind: Secret
+metadata:
+  name: credentials
+data:
+  password: Y2hhbmdlZA==
+sops:
+  nonce: 2
+`, marshal(t, third[0]))
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-weight: "-5"
+    helm.sh/hook-delete-policy: before-hook-creation,hook-succeeded
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test-connection
+  annotations:
+    helm.sh/hook: test
+`)
+
+		names := func(documents []manifest.ParsedDocument) []string {
+			var names []string
+			for _, pd := range documents {
+				names = append(names, pd.Metadata.Metadata.Name)
+			}
+
+			return names
+		}
+
+		tests := []struct {
+			policy   config.HookPolicy
+			expected []string
+		}{
+			{policy: config.HookPolicyKeep, expected: []string{"settings", "migrate", "test-connection"}},
+			{policy: config.HookPolicyDrop, expected: []string{"settings"}},
+			{policy: config.HookPolicyDropTests, expected: []string{"settings", "migrate"}},
+			{policy: config.HookPolicyArgoCD, expected: []string{"settings", "migrate"}},
+			{policy: config.HookPolicyFlux, expected: []string{"settings", "migrate"}},
+		}
+
+		for _, tt := range tests {
+			t.Run(string(tt.policy), func(t *testing.T) {
+				transformed, err := transform.Apply(config.Target{Hooks: tt.policy}, documents, transform.Options{})
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.expected, names(transformed))
+			})
+		}
+
+		transformed, err := transform.Apply(config.Target{Hooks: config.HookPolicyArgoCD}, documents, transform.Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: batch/v1
+kind: Job
+metadata:
+  annotations:
+    argocd.argoproj.io/hook: PreSync
+    argocd.argoproj.io/hook-delete-policy: BeforeHookCreation,HookSucceeded
+    argocd.argoproj.io/sync-wave: "-5"
+  name: migrate
+`, marshal(t, transformed[1]))
+
+		transformed, err = transform.Apply(config.Target{Hooks: config.HookPolicyFlux}, documents, transform.Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: batch/v1
+kind: Job
+metadata:
+  annotations:
+    kustomize.toolkit.fluxcd.io/force: Enabled
+  name: migrate
+`, marshal(t, transformed[1]))
+	})
+
+	t.Run("normalize", func(t *testing.T) {
+		documents := parseDocuments(t, `status: {}
+spec:
+  template:
+    spec:
+      volumes:
+        - name: cache
+          emptyDir: {}
+      containers:
+        - name: web
+          resources: {}
+          args: []
+          env: null
+  selector: {}
+kind: Deployment
+metadata:
+  name: web
+  creationTimestamp: null
+apiVersion: apps/v1
+extra: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  empty: ""
+`)
+
+		transformed, err := transform.Apply(config.Target{Normalize: true}, documents, transform.Options{})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  selector: {}
+  template:
+    spec:
+      containers:
+      - name: web
+      volumes:
+      - emptyDir: {}
+        name: cache
+extra: value
+`, marshal(t, transformed[0]))
+
+		assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  empty: ""
+`, marshal(t, transformed[1]))
+	})
+
+	t.Run("CRD descriptions", func(t *testing.T) {
+		documents := parseDocuments(t, `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  versions:
+    - name: v1
+      schema:
+        openAPIV3Schema:
+          description: Widget is a widget.
+          type: object
+          properties:
+            spec:
+              description: Spec of the widget.
+              type: object
+              properties:
+                description:
+                  description: Free-form text.
+                  type: string
+                sizes:
+                  description: Sizes of the widget.
+                  type: array
+                  items:
+                    description: A size.
+                    type: integer
+`)
+
+		var report strings.Builder
+
+		target := config.Target{
+			Transform: &config.Transform{CRDDescriptions: config.CRDDescriptionsKeepTopLevel},
+		}
+
+		transformed, err := transform.Apply(target, documents, transform.Options{Out: &report})
+		require.NoError(t, err)
+
+		assert.Equal(t, `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  versions:
+  - name: v1
+    schema:
+      openAPIV3Schema:
+        description: Widget is a widget.
+        properties:
+          spec:
+            description: Spec of the widget.
+            properties:
+              description:
+                type: string
+              sizes:
+                items:
+                  type: integer
+                type: array
+            type: object
+        type: object
+`, marshal(t, transformed[0]))
+		assert.Contains(t, report.String(), "Stripped descriptions of apiextensions.k8s.io/v1 CustomResourceDefinition widgets.example.com")
+
+		target.Transform.CRDDescriptions = config.CRDDescriptionsStrip
+
+		transformed, err = transform.Apply(target, documents, transform.Options{})
+		require.NoError(t, err)
+		assert.NotContains(t, marshal(t, transformed[0]), "description: ")
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		target := config.Target{
+			Transform: &config.Transform{Remove: []string{"metadata..name"}},
+		}
+
+		_, err := transform.Apply(target, nil, transform.Options{})
+		require.ErrorContains(t, err, "transform.remove[0]")
+	})
+}
+
+func parseDocuments(t *testing.T, content string) []manifest.ParsedDocument {
+	t.Helper()
+
+	documents, err := manifest.ParseDocuments([]byte(content))
+	require.NoError(t, err)
+
+	return documents
+}
+
+func marshal(t *testing.T, pd manifest.ParsedDocument) string {
+	t.Helper()
+
+	data, err := yaml.Marshal(pd.Document)
+	require.NoError(t, err)
+
+	return string(data)
+}
+
+// fakeSops imitates sops: encryption appends a sops key with a new nonce,
+// decryption removes it.
+type fakeSops struct {
+	nonce int
+}
+
+func (f *fakeSops) LookPath(file string) (string, error) {
+	return "/usr/bin/" + file, nil
+}
+
+func (f *fakeSops) Exec(name string, args ...string) ([]byte, error) {
+	content, err := os.ReadFile(args[len(args)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	if args[0] == "--decrypt" {
+		before, _, _ := strings.Cut(string(content), "sops:\n")
+		return []byte(before), nil
+	}
+
+	f.nonce++
+
+	return fmt.Appendf(content, "sops:\n  nonce: %d\n", f.nonce), nil
+}
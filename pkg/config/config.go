@@ -4,28 +4,480 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"slices"
+	"strings"
 
 	yaml "github.com/goccy/go-yaml"
 	"github.com/spf13/afero"
 )
 
 // Config represents the kubesource.yaml configuration file.
+//
+// KubernetesVersion is the version of the cluster the resources are deployed
+// to, e.g. "1.30.0". It selects the schemas resources are validated against
+// and enables reporting resources using deprecated or removed API versions.
+// Deprecations controls what happens when a resource uses an API version
+// removed in that version.
 type Config struct {
-	APIVersion string   `yaml:"apiVersion"`
-	Kind       string   `yaml:"kind"`
-	Sources    []Source `yaml:"sources"`
+	APIVersion        string            `yaml:"apiVersion"`
+	Kind              string            `yaml:"kind"`
+	KubernetesVersion string            `yaml:"kubernetesVersion,omitempty"`
+	Deprecations      StrictMode        `yaml:"deprecations,omitempty"`
+	Filters           map[string]Filter `yaml:"filters,omitempty"`
+	Sources           []Source          `yaml:"sources"`
 }
 
 // Source represents a source directory and its associated targets.
+//
+// Drop lists resources that are intentionally not written to any target; they
+// are removed before targets are filtered. Coverage controls what happens when
+// a resource is neither written to a target nor dropped. Validation controls
+// what happens when a rendered resource has malformed metadata, such as a
+// missing kind or a label with a non-string value.
+//
+// Images apply to every target of the source, see Images.Merge.
+//
+// Schemas validates the resources written to every target against JSON schemas.
 type Source struct {
-	SourceDir string   `yaml:"sourceDir"`
-	Targets   []Target `yaml:"targets"`
+	SourceDir  string     `yaml:"sourceDir"`
+	Drop       []Selector `yaml:"drop,omitempty"`
+	Coverage   StrictMode `yaml:"coverage,omitempty"`
+	Validation StrictMode `yaml:"validation,omitempty"`
+	Images     *Images    `yaml:"images,omitempty"`
+	Schemas    *Schemas   `yaml:"schemas,omitempty"`
+	Targets    []Target   `yaml:"targets"`
+}
+
+// Schemas configures validation of written resources against JSON schemas.
+// Resources are validated against schemas derived from the rendered
+// CustomResourceDefinitions and those in CRDs, then schemas in Directories,
+// then schemas of common built-in resources bundled with kubesource.
+type Schemas struct {
+	// Mode is StrictError, the default, or StrictWarn.
+	Mode StrictMode `yaml:"mode,omitempty"`
+	// Directories hold JSON schemas in the layout of kubernetes-json-schema,
+	// e.g. "v1.30.0-standalone/deployment-apps-v1.json", or of the CRDs
+	// catalog, e.g. "cert-manager.io/certificate_v1.json".
+	Directories []string `yaml:"directories,omitempty"`
+	// CRDs are YAML files, or directories of them, with CustomResourceDefinitions.
+	CRDs []string `yaml:"crds,omitempty"`
 }
 
 // Target represents a target directory where rendered manifests should be saved.
+//
+// Filters references named filters declared in Config.Filters. A resource is
+// written only if it passes every referenced filter and the inline Filter.
+//
+// Namespace moves namespaced resources to the namespace and rewrites
+// references to the namespaces they were rendered in.
+//
+// Labels and Annotations are added to every resource written to the target.
+//
+// Normalize rewrites every resource in canonical form for stable diffs.
+//
+// Nameless controls how resources with neither metadata.name nor
+// metadata.generateName are written, see NamelessPolicy.
+//
+// Format defines how resources are written, see Format. Chart describes the
+// Helm chart written with FormatHelm.
+//
+// Kustomization configures the kustomization.yaml written to the directory.
+//
+// Strict controls what happens when a selector of the target's filters does
+// not match any rendered resource: StrictWarn prints a warning and StrictError
+// fails the run. An empty value disables the check.
 type Target struct {
-	Directory string  `yaml:"directory"`
-	Filter    *Filter `yaml:"filter,omitempty"`
+	Directory     string          `yaml:"directory"`
+	Filters       []string        `yaml:"filters,omitempty"`
+	Filter        *Filter         `yaml:"filter,omitempty"`
+	Namespace     string          `yaml:"namespace,omitempty"`
+	Labels        *CommonMetadata `yaml:"labels,omitempty"`
+	Annotations   *CommonMetadata `yaml:"annotations,omitempty"`
+	Images        *Images         `yaml:"images,omitempty"`
+	Secrets       *Secrets        `yaml:"secrets,omitempty"`
+	Hooks         HookPolicy      `yaml:"hooks,omitempty"`
+	Normalize     bool            `yaml:"normalize,omitempty"`
+	Nameless      NamelessPolicy  `yaml:"nameless,omitempty"`
+	Strict        StrictMode      `yaml:"strict,omitempty"`
+	Transform     *Transform      `yaml:"transform,omitempty"`
+	Format        Format          `yaml:"format,omitempty"`
+	Chart         *Chart          `yaml:"chart,omitempty"`
+	Kustomization *Kustomization  `yaml:"kustomization,omitempty"`
+}
+
+// CommonMetadata represents labels or annotations added to written resources.
+// If IncludeTemplates is set, they are added to pod templates of workloads as
+// well. Selectors are never modified.
+type CommonMetadata struct {
+	Pairs            map[string]string `yaml:"pairs"`
+	IncludeTemplates bool              `yaml:"includeTemplates,omitempty"`
+}
+
+// Images represents modifications of container images.
+//
+// Images are found in containers, init containers and ephemeral containers of
+// Pods, Deployments, StatefulSets, DaemonSets, ReplicaSets, ReplicationControllers,
+// Jobs and CronJobs. Paths adds image fields of other resources, such as custom
+// resources.
+type Images struct {
+	Rewrite []ImageRewrite `yaml:"rewrite,omitempty"`
+	Paths   []ImagePath    `yaml:"paths,omitempty"`
+	Digests *ImageDigests  `yaml:"digests,omitempty"`
+}
+
+// ImageDigests pins images to digests, so every image tag is followed by
+// "@sha256:...". Pinning fails if the digest of an image is unknown.
+//
+// File is a YAML mapping of images to digests, relative to the directory of
+// kubesource.yaml. Images are looked up after rewrites are applied. If Resolve
+// is set, digests missing from File are resolved from the image registry.
+type ImageDigests struct {
+	File    string `yaml:"file,omitempty"`
+	Resolve bool   `yaml:"resolve,omitempty"`
+}
+
+// ImageRewrite replaces the registry or repository prefix From of an image
+// with To, e.g. "quay.io/jetstack" with "mirror.example.com/jetstack".
+// Prefixes without a registry refer to Docker Hub ("docker.io").
+type ImageRewrite struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+// ImagePath is a field path to an image string in resources matching Target,
+// e.g. "spec.image".
+type ImagePath struct {
+	Target Selector `yaml:"target"`
+	Path   string   `yaml:"path"`
+}
+
+// Merge returns images combined with other. Rewrites of images take precedence
+// over rewrites of other with the same prefix, and Digests of images replaces
+// Digests of other.
+func (i *Images) Merge(other *Images) *Images {
+	if i == nil {
+		return other
+	}
+
+	if other == nil {
+		return i
+	}
+
+	digests := i.Digests
+	if digests == nil {
+		digests = other.Digests
+	}
+
+	return &Images{
+		Rewrite: append(slices.Clone(i.Rewrite), other.Rewrite...),
+		Paths:   append(slices.Clone(i.Paths), other.Paths...),
+		Digests: digests,
+	}
+}
+
+func (i *Images) validate() error {
+	if i == nil || i.Digests == nil {
+		return nil
+	}
+
+	if i.Digests.File == "" && !i.Digests.Resolve {
+		return errors.New("digests requires file or resolve")
+	}
+
+	return nil
+}
+
+// HookPolicy defines how Helm hook resources (annotated with helm.sh/hook) are written.
+type HookPolicy string
+
+const (
+	// HookPolicyKeep writes hooks as regular resources.
+	HookPolicyKeep HookPolicy = "keep"
+	// HookPolicyDrop removes all hooks.
+	HookPolicyDrop HookPolicy = "drop"
+	// HookPolicyDropTests removes test hooks only.
+	HookPolicyDropTests HookPolicy = "drop-tests"
+	// HookPolicyArgoCD converts hooks to Argo CD hooks and removes hooks without an equivalent.
+	HookPolicyArgoCD HookPolicy = "argocd"
+	// HookPolicyFlux converts install and upgrade hooks to regular resources
+	// recreated by Flux on change and removes other hooks.
+	HookPolicyFlux HookPolicy = "flux"
+)
+
+// Validate returns an error if the policy is not a known HookPolicy.
+func (p HookPolicy) Validate() error {
+	switch p {
+	case "", HookPolicyKeep, HookPolicyDrop, HookPolicyDropTests, HookPolicyArgoCD, HookPolicyFlux:
+		return nil
+	}
+
+	return fmt.Errorf("invalid policy %q, must be %q, %q, %q, %q or %q", p, HookPolicyKeep, HookPolicyDrop, HookPolicyDropTests, HookPolicyArgoCD, HookPolicyFlux)
+}
+
+// NamelessPolicy defines how resources with neither metadata.name nor
+// metadata.generateName are written.
+type NamelessPolicy string
+
+const (
+	// NamelessPolicyError fails the run. This is the default.
+	NamelessPolicyError NamelessPolicy = "error"
+	// NamelessPolicySkip omits the resource with a warning.
+	NamelessPolicySkip NamelessPolicy = "skip"
+	// NamelessPolicySuffix writes the resource to a file named after a hash of its content.
+	NamelessPolicySuffix NamelessPolicy = "suffix"
+)
+
+// Validate returns an error if the policy is not a known NamelessPolicy.
+func (p NamelessPolicy) Validate() error {
+	switch p {
+	case "", NamelessPolicyError, NamelessPolicySkip, NamelessPolicySuffix:
+		return nil
+	}
+
+	return fmt.Errorf("invalid policy %q, must be %q, %q or %q", p, NamelessPolicyError, NamelessPolicySkip, NamelessPolicySuffix)
+}
+
+// SecretPolicy defines how Secrets are written.
+type SecretPolicy string
+
+const (
+	// SecretPolicyKeep writes Secrets as they were rendered.
+	SecretPolicyKeep SecretPolicy = "keep"
+	// SecretPolicyRedact replaces every value of data and stringData with a placeholder.
+	SecretPolicyRedact SecretPolicy = "redact"
+	// SecretPolicyDropData removes data and stringData.
+	SecretPolicyDropData SecretPolicy = "drop-data"
+	// SecretPolicyEncrypt encrypts data and stringData with sops for age recipients.
+	SecretPolicyEncrypt SecretPolicy = "encrypt"
+)
+
+// Secrets represents the handling of Secrets written to a target.
+// Placeholder is used by SecretPolicyRedact and defaults to "REDACTED".
+// Age lists age recipients used by SecretPolicyEncrypt.
+type Secrets struct {
+	Policy      SecretPolicy `yaml:"policy"`
+	Placeholder string       `yaml:"placeholder,omitempty"`
+	Age         []string     `yaml:"age,omitempty"`
+}
+
+func (s *Secrets) validate() error {
+	if s == nil {
+		return nil
+	}
+
+	switch s.Policy {
+	case SecretPolicyKeep, SecretPolicyRedact, SecretPolicyDropData:
+		return nil
+
+	case SecretPolicyEncrypt:
+		if len(s.Age) == 0 {
+			return fmt.Errorf("policy %q requires at least one age recipient", s.Policy)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("invalid policy %q, must be %q, %q, %q or %q", s.Policy, SecretPolicyKeep, SecretPolicyRedact, SecretPolicyDropData, SecretPolicyEncrypt)
+}
+
+// Format defines how resources are written to a target directory.
+type Format string
+
+const (
+	// FormatYAML writes every resource to its own YAML file. This is the default.
+	FormatYAML Format = "yaml"
+	// FormatJSON writes every resource to its own JSON file.
+	FormatJSON Format = "json"
+	// FormatBundle writes all resources to a single multi-document YAML file.
+	FormatBundle Format = "bundle"
+	// FormatHelm writes a Helm chart with every resource as a template.
+	FormatHelm Format = "helm"
+)
+
+// Validate returns an error if the format is not a known Format.
+func (f Format) Validate() error {
+	switch f {
+	case "", FormatYAML, FormatJSON, FormatBundle, FormatHelm:
+		return nil
+	}
+
+	return fmt.Errorf("invalid format %q, must be %q, %q, %q or %q", f, FormatYAML, FormatJSON, FormatBundle, FormatHelm)
+}
+
+// Chart describes the Helm chart written by FormatHelm. Name defaults to the
+// base name of the target directory and Version to 0.1.0.
+type Chart struct {
+	Name        string `yaml:"name,omitempty"`
+	Version     string `yaml:"version,omitempty"`
+	AppVersion  string `yaml:"appVersion,omitempty"`
+	Description string `yaml:"description,omitempty"`
+}
+
+// Kustomization configures the kustomization.yaml written to a target directory.
+type Kustomization struct {
+	// Disabled stops kustomization.yaml from being written.
+	Disabled bool `yaml:"disabled,omitempty"`
+	// Namespace and Labels are set in the kustomization.
+	Namespace string            `yaml:"namespace,omitempty"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+	// Resources and Patches reference hand-written files kept in the target directory.
+	Resources []string `yaml:"resources,omitempty"`
+	Patches   []string `yaml:"patches,omitempty"`
+	// Merge updates the resources of a user-owned kustomization.yaml instead of overwriting it.
+	Merge bool `yaml:"merge,omitempty"`
+}
+
+// KeepsUserFiles reports whether the target directory contains hand-written
+// files that must survive a run, so only generated files may be removed.
+func (k *Kustomization) KeepsUserFiles() bool {
+	return k != nil && (k.Merge || len(k.Resources) > 0 || len(k.Patches) > 0)
+}
+
+func (k *Kustomization) validate() error {
+	if k == nil || !k.Disabled {
+		return nil
+	}
+
+	if k.Namespace != "" || len(k.Labels) > 0 || len(k.Resources) > 0 || len(k.Patches) > 0 || k.Merge {
+		return errors.New("disabled cannot be combined with other settings")
+	}
+
+	return nil
+}
+
+// Transform represents modifications applied to every document written to a target.
+type Transform struct {
+	// Remove lists field paths to delete, e.g. "status" or "metadata.labels[helm.sh/chart]".
+	// Keys containing dots are wrapped in square brackets, "[*]" matches every
+	// list item or map value and "[N]" selects the N-th list item.
+	Remove []string `yaml:"remove,omitempty"`
+
+	// Patches are applied in order after fields are removed.
+	Patches []Patch `yaml:"patches,omitempty"`
+
+	// CRDDescriptions removes description fields from the schemas of
+	// CustomResourceDefinitions, which can exceed the size limit of
+	// client-side apply.
+	CRDDescriptions CRDDescriptionPolicy `yaml:"crdDescriptions,omitempty"`
+}
+
+// CRDDescriptionPolicy defines which description fields are kept in the
+// schemas of CustomResourceDefinitions.
+type CRDDescriptionPolicy string
+
+const (
+	// CRDDescriptionsKeep keeps all descriptions. This is the default.
+	CRDDescriptionsKeep CRDDescriptionPolicy = "keep"
+	// CRDDescriptionsStrip removes all descriptions.
+	CRDDescriptionsStrip CRDDescriptionPolicy = "strip"
+	// CRDDescriptionsKeepTopLevel removes all descriptions except those of the
+	// schema itself and its properties, such as spec and status.
+	CRDDescriptionsKeepTopLevel CRDDescriptionPolicy = "keep-top-level"
+)
+
+// Validate returns an error if the policy is not a known CRDDescriptionPolicy.
+func (p CRDDescriptionPolicy) Validate() error {
+	switch p {
+	case "", CRDDescriptionsKeep, CRDDescriptionsStrip, CRDDescriptionsKeepTopLevel:
+		return nil
+	}
+
+	return fmt.Errorf("invalid policy %q, must be %q, %q or %q", p, CRDDescriptionsKeep, CRDDescriptionsStrip, CRDDescriptionsKeepTopLevel)
+}
+
+// Patch represents a JSON 6902 or strategic merge patch.
+//
+// Patch holds either a list of JSON 6902 operations or a strategic merge patch
+// object. The patch is applied to resources matching Target; a strategic merge
+// patch without Target applies to the resource identified by its own apiVersion,
+// kind, metadata.name and metadata.namespace, so it must have at least a kind
+// and a name.
+type Patch struct {
+	Target *Selector `yaml:"target,omitempty"`
+	Patch  string    `yaml:"patch"`
+}
+
+// Content parses the patch and returns either a list of JSON 6902 operations
+// ([]any) or a strategic merge patch object (map[string]any).
+func (p Patch) Content() (any, error) {
+	var content any
+	if err := yaml.Unmarshal([]byte(p.Patch), &content); err != nil {
+		return nil, fmt.Errorf("parsing patch: %w", err)
+	}
+
+	switch content := content.(type) {
+	case []any:
+		for i, operation := range content {
+			operation, ok := operation.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("operation %d must be an object", i)
+			}
+
+			if _, ok := operation["op"].(string); !ok {
+				return nil, fmt.Errorf("operation %d: op is required", i)
+			}
+
+			if _, ok := operation["path"].(string); !ok {
+				return nil, fmt.Errorf("operation %d: path is required", i)
+			}
+		}
+
+		return content, nil
+
+	case map[string]any:
+		return content, nil
+	}
+
+	return nil, errors.New("patch must be a list of JSON 6902 operations or a strategic merge patch object")
+}
+
+func (p Patch) validate() error {
+	content, err := p.Content()
+	if err != nil {
+		return err
+	}
+
+	object, ok := content.(map[string]any)
+	if !ok || p.Target != nil {
+		return nil
+	}
+
+	// without a target, the patch would apply to every resource
+	kind, _ := object["kind"].(string)
+	metadata, _ := object["metadata"].(map[string]any)
+	name, _ := metadata["name"].(string)
+
+	if kind == "" || name == "" {
+		return errors.New("strategic merge patch without target must have kind and metadata.name")
+	}
+
+	return nil
+}
+
+// StrictMode defines how problems such as selectors that never match are reported.
+type StrictMode string
+
+const (
+	StrictOff   StrictMode = ""
+	StrictWarn  StrictMode = "warn"
+	StrictError StrictMode = "error"
+)
+
+// Validate returns an error if the mode is not a known StrictMode.
+func (m StrictMode) Validate() error {
+	switch m {
+	case StrictOff, StrictWarn, StrictError:
+		return nil
+	}
+
+	return fmt.Errorf("invalid mode %q, must be %q or %q", m, StrictWarn, StrictError)
+}
+
+// TargetFilter is a filter applied to a target along with the path it was declared at,
+// either "filters.<name>" for named filters or "filter" for the inline filter.
+type TargetFilter struct {
+	Path   string
+	Filter *Filter
 }
 
 // Filter represents filtering options for a target.
@@ -36,18 +488,32 @@ type Filter struct {
 	Exclude []Selector `yaml:"exclude,omitempty"`
 }
 
+// CoreGroup is the value of Selector.Group that matches resources from the
+// Kubernetes core API group (apiVersion without a group, such as "v1").
+const CoreGroup = "core"
+
 // Selector represents a resource selector for filtering. All non-empty fields must match.
+//
+// APIVersion matches the full "group/version" string. Group and Version match
+// the individual parts of apiVersion, so a selector can target an API group
+// regardless of its version.
 type Selector struct {
 	Kind       string            `yaml:"kind,omitempty"`
 	APIVersion string            `yaml:"apiVersion,omitempty"`
+	Group      string            `yaml:"group,omitempty"`
+	Version    string            `yaml:"version,omitempty"`
 	Metadata   *MetadataSelector `yaml:"metadata,omitempty"`
 }
 
 // MetadataSelector represents metadata-based filtering.
+//
+// Name matches metadata.name only; resources named by the API server match
+// GenerateName, their metadata.generateName prefix.
 type MetadataSelector struct {
-	Name      string            `yaml:"name,omitempty"`
-	Namespace string            `yaml:"namespace,omitempty"`
-	Labels    map[string]string `yaml:"labels,omitempty"`
+	Name         string            `yaml:"name,omitempty"`
+	GenerateName string            `yaml:"generateName,omitempty"`
+	Namespace    string            `yaml:"namespace,omitempty"`
+	Labels       map[string]string `yaml:"labels,omitempty"`
 }
 
 // LoadConfig loads and parses a kubesource.yaml file from the specified directory using afero.Fs
@@ -59,23 +525,76 @@ func LoadConfig(afs afero.Fs, dir string) (*Config, error) {
 		return nil, fmt.Errorf("reading kubesource.yaml from %s: %w", dir, err)
 	}
 
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading kubesource.yaml from %s: %w", dir, err)
+	}
+
+	return config, nil
+}
+
+// ParseConfig parses and validates the content of a kubesource.yaml file.
+func ParseConfig(data []byte) (*Config, error) {
 	var config Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("parsing kubesource.yaml from %s: %w", dir, err)
+		return nil, fmt.Errorf("parsing: %w", err)
 	}
 
 	if err := validateConfig(config); err != nil {
-		return nil, fmt.Errorf("validating kubesource.yaml from %s: %w", dir, err)
+		return nil, fmt.Errorf("validating: %w", err)
 	}
 
 	return &config, nil
 }
 
+// String returns the selector in YAML flow style, e.g. "{kind: Secret}".
+func (s Selector) String() string {
+	data, err := yaml.MarshalWithOptions(s, yaml.Flow(true))
+	if err != nil {
+		type plain Selector
+
+		return fmt.Sprintf("%+v", plain(s))
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+// TargetFilters returns the named filters referenced by target followed by its
+// inline filter. Unknown names are skipped; LoadConfig rejects them.
+func (c *Config) TargetFilters(target Target) []TargetFilter {
+	filters := make([]TargetFilter, 0, len(target.Filters)+1)
+
+	for _, name := range target.Filters {
+		filter, ok := c.Filters[name]
+		if !ok {
+			continue
+		}
+
+		filters = append(filters, TargetFilter{
+			Path:   "filters." + name,
+			Filter: &filter,
+		})
+	}
+
+	if target.Filter != nil {
+		filters = append(filters, TargetFilter{
+			Path:   "filter",
+			Filter: target.Filter,
+		})
+	}
+
+	return filters
+}
+
 func validateConfig(config Config) error {
 	if len(config.Sources) == 0 {
 		return errors.New("at least one source is required")
 	}
 
+	if err := config.Deprecations.Validate(); err != nil {
+		return fmt.Errorf("deprecations: %w", err)
+	}
+
 	for i, source := range config.Sources {
 		if source.SourceDir == "" {
 			return fmt.Errorf("sources[%d].sourceDir is required", i)
@@ -84,6 +603,80 @@ func validateConfig(config Config) error {
 		if len(source.Targets) == 0 {
 			return fmt.Errorf("sources[%d] must have at least one target", i)
 		}
+
+		if err := source.Coverage.Validate(); err != nil {
+			return fmt.Errorf("sources[%d].coverage: %w", i, err)
+		}
+
+		if err := source.Validation.Validate(); err != nil {
+			return fmt.Errorf("sources[%d].validation: %w", i, err)
+		}
+
+		if source.Schemas != nil {
+			if err := source.Schemas.Mode.Validate(); err != nil {
+				return fmt.Errorf("sources[%d].schemas.mode: %w", i, err)
+			}
+		}
+
+		if err := source.Images.validate(); err != nil {
+			return fmt.Errorf("sources[%d].images: %w", i, err)
+		}
+
+		for j, target := range source.Targets {
+			if err := target.Strict.Validate(); err != nil {
+				return fmt.Errorf("sources[%d].targets[%d].strict: %w", i, j, err)
+			}
+
+			if err := target.Hooks.Validate(); err != nil {
+				return fmt.Errorf("sources[%d].targets[%d].hooks: %w", i, j, err)
+			}
+
+			if err := target.Nameless.Validate(); err != nil {
+				return fmt.Errorf("sources[%d].targets[%d].nameless: %w", i, j, err)
+			}
+
+			if err := target.Secrets.validate(); err != nil {
+				return fmt.Errorf("sources[%d].targets[%d].secrets: %w", i, j, err)
+			}
+
+			if err := target.Images.validate(); err != nil {
+				return fmt.Errorf("sources[%d].targets[%d].images: %w", i, j, err)
+			}
+
+			if err := target.Kustomization.validate(); err != nil {
+				return fmt.Errorf("sources[%d].targets[%d].kustomization: %w", i, j, err)
+			}
+
+			if target.Transform != nil {
+				if err := target.Transform.CRDDescriptions.Validate(); err != nil {
+					return fmt.Errorf("sources[%d].targets[%d].transform.crdDescriptions: %w", i, j, err)
+				}
+
+				for k, patch := range target.Transform.Patches {
+					if err := patch.validate(); err != nil {
+						return fmt.Errorf("sources[%d].targets[%d].transform.patches[%d]: %w", i, j, k, err)
+					}
+				}
+			}
+
+			if err := target.Format.Validate(); err != nil {
+				return fmt.Errorf("sources[%d].targets[%d].format: %w", i, j, err)
+			}
+
+			if target.Format == FormatHelm && target.Kustomization != nil {
+				return fmt.Errorf("sources[%d].targets[%d].kustomization cannot be used with format %q", i, j, FormatHelm)
+			}
+
+			if target.Format != FormatHelm && target.Chart != nil {
+				return fmt.Errorf("sources[%d].targets[%d].chart requires format %q", i, j, FormatHelm)
+			}
+
+			for k, name := range target.Filters {
+				if _, ok := config.Filters[name]; !ok {
+					return fmt.Errorf("sources[%d].targets[%d].filters[%d] references unknown filter %q", i, j, k, name)
+				}
+			}
+		}
 	}
 
 	return nil
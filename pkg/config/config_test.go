@@ -0,0 +1,130 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/artuross/kubesource/pkg/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("named filters", func(t *testing.T) {
+		afs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(afs, "app/kubesource.yaml", []byte(`apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+filters:
+  no-crds:
+    exclude:
+      - kind: CustomResourceDefinition
+sources:
+  - sourceDir: ./source
+    targets:
+      - directory: ./app
+        filters:
+          - no-crds
+        filter:
+          exclude:
+            - kind: Secret
+`), 0o644))
+
+		cfg, err := config.LoadConfig(afs, "app")
+		require.NoError(t, err)
+
+		filters := cfg.TargetFilters(cfg.Sources[0].Targets[0])
+		require.Len(t, filters, 2)
+		assert.Equal(t, "filters.no-crds", filters[0].Path)
+		assert.Equal(t, "CustomResourceDefinition", filters[0].Filter.Exclude[0].Kind)
+		assert.Equal(t, "filter", filters[1].Path)
+		assert.Equal(t, "Secret", filters[1].Filter.Exclude[0].Kind)
+	})
+
+	t.Run("unknown named filter", func(t *testing.T) {
+		afs := afero.NewMemMapFs()
+		require.NoError(t, afero.WriteFile(afs, "app/kubesource.yaml", []byte(`apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+sources:
+  - sourceDir: ./source
+    targets:
+      - directory: ./app
+        filters:
+          - missing
+`), 0o644))
+
+		_, err := config.LoadConfig(afs, "app")
+		require.ErrorContains(t, err, `sources[0].targets[0].filters[0] references unknown filter "missing"`)
+	})
+
+	t.Run("patches", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			patches string
+			err     string
+		}{
+			{
+				name: "strategic merge patch identifying its resource",
+				patches: `
+          - patch: |
+              kind: Deployment
+              metadata:
+                name: web
+              spec:
+                replicas: 2`,
+			},
+			{
+				name: "strategic merge patch with target",
+				patches: `
+          - target:
+              kind: Deployment
+            patch: |
+              spec:
+                replicas: 2`,
+			},
+			{
+				name: "strategic merge patch without target and name",
+				patches: `
+          - patch: |
+              spec:
+                replicas: 2`,
+				err: "sources[0].targets[0].transform.patches[0]: strategic merge patch without target must have kind and metadata.name",
+			},
+			{
+				name: "JSON 6902 operation without path",
+				patches: `
+          - patch: |
+              - op: remove`,
+				err: "sources[0].targets[0].transform.patches[0]: operation 0: path is required",
+			},
+			{
+				name: "scalar patch",
+				patches: `
+          - patch: replicas`,
+				err: "sources[0].targets[0].transform.patches[0]: patch must be a list of JSON 6902 operations or a strategic merge patch object",
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				afs := afero.NewMemMapFs()
+				require.NoError(t, afero.WriteFile(afs, "app/kubesource.yaml", []byte(`apiVersion: kubesource.rcwz.pl/v1alpha2
+kind: Config
+sources:
+  - sourceDir: ./source
+    targets:
+      - directory: ./app
+        transform:
+          patches:`+tt.patches+"\n"), 0o644))
+
+				_, err := config.LoadConfig(afs, "app")
+				if tt.err == "" {
+					require.NoError(t, err)
+					return
+				}
+
+				require.ErrorContains(t, err, tt.err)
+			})
+		}
+	})
+}